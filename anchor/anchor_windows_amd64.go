@@ -9,8 +9,8 @@ import (
 	"path/filepath"
 
 	pb "github.com/veil-net/conflux/proto"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 // anchorPlugin is the embedded anchor binary for this GOOS/GOARCH.
@@ -25,6 +25,17 @@ var anchorPlugin []byte
 //   - *exec.Cmd. The started anchor subprocess.
 //   - err: error. Non-nil if the binary cannot be extracted or started.
 func NewAnchor() (*exec.Cmd, error) {
+	return newAnchor(Logger)
+}
+
+// newAnchor implements NewAnchor; the subprocess output is forwarded through logger and extraFiles are inherited by
+// the subprocess as descriptors 3, 4, ...
+func newAnchor(logger *zap.Logger, extraFiles ...*os.File) (*exec.Cmd, error) {
+	// Spawn the anchor command from the environment instead in dev builds, e.g. the fake anchor
+	if command := anchorCommand(); command != "" {
+		return startAnchorCommand(command, logger, extraFiles)
+	}
+
 	// Extract the embedded file to a temporary directory
 	pluginPath := filepath.Join(os.TempDir(), "anchor.exe")
 	// Remove existing file if it exists to avoid "text file busy" error
@@ -35,9 +46,10 @@ func NewAnchor() (*exec.Cmd, error) {
 
 	// Start the anchor binary as a manageable subprocess (runs the gRPC server)
 	cmd := exec.Command(pluginPath)
-	// Link stdout and stderr to see logs from the subprocess
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Capture stdout and stderr to forward the subprocess logs through the logger
+	cmd.Stdout = newLogWriter("stdout", logger)
+	cmd.Stderr = newLogWriter("stderr", logger)
+	cmd.ExtraFiles = extraFiles
 
 	if err := cmd.Start(); err != nil {
 		return nil, err
@@ -51,16 +63,16 @@ func NewAnchor() (*exec.Cmd, error) {
 	return cmd, nil
 }
 
-// NewAnchorClient creates a gRPC client connected to the local anchor server (127.0.0.1:1993).
+// NewAnchorClient creates a gRPC client connected to the local anchor server (127.0.0.1:1993, or VEILNET_ANCHOR_ADDRESS).
 //
 // Inputs: none.
 //
 // Outputs:
-//   - pb.AnchorClient. The gRPC client connected to 127.0.0.1:1993.
+//   - pb.AnchorClient. The gRPC client connected to Address().
 //   - err: error. Non-nil if the connection fails.
 func NewAnchorClient() (pb.AnchorClient, error) {
 	// Create a gRPC client connection
-	conn, err := grpc.NewClient("127.0.0.1:1993", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(Address(), dialOptions()...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,453 @@
+package anchor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	pb "github.com/veil-net/conflux/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// DefaultGuardian is the Guardian URL used when none is configured.
+const DefaultGuardian = "https://guardian.veilnet.app"
+
+// anchorReadyTimeout bounds the wait for a spawned anchor to listen on its control endpoint.
+const anchorReadyTimeout = 10 * time.Second
+
+// ErrConfluxStarted is returned by Conflux.Start when the conflux was already started.
+var ErrConfluxStarted = errors.New("conflux already started")
+
+// Conflux is a conflux embedded in a Go program: Start registers it (or uses its conflux token), spawns the anchor and
+// starts it; the conflux runs until Stop is called, the context given to Start is cancelled or the anchor exits.
+type Conflux struct {
+	options confluxOptions
+
+	mu         sync.Mutex
+	started    bool
+	stopping   bool
+	subprocess *exec.Cmd
+	exited     chan struct{}
+	exitErr    error
+	conn       *grpc.ClientConn
+	client     pb.AnchorClient
+	config     *ConfluxConfig
+	done       chan struct{}
+	err        error
+}
+
+// confluxOptions holds the settings applied by the Options of NewConflux.
+type confluxOptions struct {
+	guardian          string
+	registrationToken string
+	confluxID         string
+	token             string
+	tag               string
+	ip                string
+	rift              bool
+	portal            bool
+	conduit           bool
+	taints            []string
+	tracer            *TracerConfig
+	idp               *IDPConfig
+	controlEndpoint   string
+	tun               *os.File
+	logger            *zap.Logger
+}
+
+// Option configures a Conflux.
+type Option func(*confluxOptions)
+
+// WithGuardian sets the Guardian URL, default: https://guardian.veilnet.app.
+func WithGuardian(url string) Option {
+	return func(o *confluxOptions) { o.guardian = url }
+}
+
+// WithRegistrationToken registers a new conflux with the registration token on Start.
+func WithRegistrationToken(token string) Option {
+	return func(o *confluxOptions) { o.registrationToken = token }
+}
+
+// WithConfluxToken uses an already registered conflux instead of registering one.
+func WithConfluxToken(confluxID string, token string) Option {
+	return func(o *confluxOptions) { o.confluxID, o.token = confluxID, token }
+}
+
+// WithTag sets the tag a new conflux is registered with.
+func WithTag(tag string) Option {
+	return func(o *confluxOptions) { o.tag = tag }
+}
+
+// WithIP sets the IP of the conflux.
+func WithIP(ip string) Option {
+	return func(o *confluxOptions) { o.ip = ip }
+}
+
+// WithRift enables rift mode.
+func WithRift(enabled bool) Option {
+	return func(o *confluxOptions) { o.rift = enabled }
+}
+
+// WithPortal enables portal mode.
+func WithPortal(enabled bool) Option {
+	return func(o *confluxOptions) { o.portal = enabled }
+}
+
+// WithConduit enables conduit mode.
+func WithConduit(enabled bool) Option {
+	return func(o *confluxOptions) { o.conduit = enabled }
+}
+
+// WithTaints sets the taints added once the anchor started.
+func WithTaints(taints ...string) Option {
+	return func(o *confluxOptions) { o.taints = append([]string(nil), taints...) }
+}
+
+// WithTracer sets the OTLP tracer config of the anchor.
+func WithTracer(tracer *TracerConfig) Option {
+	return func(o *confluxOptions) { o.tracer = tracer }
+}
+
+// WithIDP registers with a JWT of an identity provider.
+func WithIDP(idp *IDPConfig) Option {
+	return func(o *confluxOptions) { o.idp = idp }
+}
+
+// WithControlEndpoint uses the anchor already serving gRPC at address instead of spawning the embedded one.
+func WithControlEndpoint(address string) Option {
+	return func(o *confluxOptions) { o.controlEndpoint = address }
+}
+
+// WithTUN passes tun to the spawned anchor instead of letting it create its TUN, so the program needs no privilege to
+// create one; not supported on Windows or with WithControlEndpoint.
+func WithTUN(tun *os.File) Option {
+	return func(o *confluxOptions) { o.tun = tun }
+}
+
+// WithLogger sets the logger of the conflux and of the output of its anchor subprocess, default: the global logger.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *confluxOptions) { o.logger = logger }
+}
+
+// NewConflux returns a conflux configured by options; nothing runs until Start.
+//
+// Inputs:
+//   - options: ...Option. The settings; WithRegistrationToken or WithConfluxToken is required.
+//
+// Outputs:
+//   - *Conflux. The conflux.
+func NewConflux(options ...Option) *Conflux {
+	c := &Conflux{
+		options: confluxOptions{guardian: DefaultGuardian, logger: Logger},
+		done:    make(chan struct{}),
+	}
+	for _, option := range options {
+		option(&c.options)
+	}
+	return c
+}
+
+// Start registers the conflux unless a conflux token was given, spawns the anchor unless a control endpoint was
+// given, starts it and adds the taints. The conflux stops when ctx is cancelled.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the startup and the lifetime of the conflux.
+//
+// Outputs:
+//   - err: error. Non-nil if the conflux was already started, or registration or the anchor start fails; the spawned
+//     anchor is killed in that case.
+func (c *Conflux) Start(ctx context.Context) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return ErrConfluxStarted
+	}
+	c.started = true
+	defer func() {
+		if err != nil {
+			if c.subprocess != nil {
+				c.subprocess.Process.Kill()
+				<-c.exited
+			}
+			c.finish(err)
+		}
+	}()
+	log := c.options.logger.Sugar()
+
+	// Register the conflux
+	config, err := c.register()
+	if err != nil {
+		log.Errorf("failed to register conflux: %v", err)
+		return err
+	}
+
+	// Spawn the anchor and wait until it listens
+	if c.options.tun != nil {
+		if c.options.controlEndpoint != "" {
+			return errors.New("a TUN can only be passed to the spawned anchor, not to a control endpoint")
+		}
+		config.TUNFD = TUNFileDescriptor
+	}
+	address := c.options.controlEndpoint
+	if address == "" {
+		address = Address()
+		// Forward the anchor output through the logger of the conflux
+		if c.options.tun != nil {
+			c.subprocess, err = newAnchor(c.options.logger, c.options.tun)
+		} else {
+			c.subprocess, err = newAnchor(c.options.logger)
+		}
+		if err != nil {
+			log.Errorf("failed to initialize anchor subprocess: %v", err)
+			return err
+		}
+		c.exited = make(chan struct{})
+		go func() {
+			// exitErr is read only after exited is closed
+			c.exitErr = <-WaitAnchor(c.subprocess)
+			close(c.exited)
+		}()
+	}
+	if err := waitListening(ctx, address); err != nil {
+		log.Errorf("anchor is not listening on %s: %v", address, err)
+		return err
+	}
+	if c.conn, err = grpc.NewClient(address, dialOptions()...); err != nil {
+		log.Errorf("failed to create anchor gRPC client: %v", err)
+		return err
+	}
+	c.client = pb.NewAnchorClient(c.conn)
+
+	// Start the anchor and add the taints
+	if err := StartAnchor(ctx, c.client, config); err != nil {
+		log.Errorf("failed to start anchor: %v", err)
+		return err
+	}
+	for _, taint := range config.Taints {
+		if _, err := c.client.AddTaint(ctx, &pb.AddTaintRequest{Taint: taint}); err != nil {
+			log.Errorf("failed to add taint: %v", err)
+			return err
+		}
+	}
+	c.config = config
+
+	go c.watch(ctx)
+	return nil
+}
+
+// register returns the config of the conflux, registering it with the registration token unless a conflux token
+// was given.
+func (c *Conflux) register() (*ConfluxConfig, error) {
+	o := c.options
+	config := &ConfluxConfig{
+		ConfluxID: o.confluxID,
+		Token:     o.token,
+		Guardian:  o.guardian,
+		Rift:      o.rift,
+		Portal:    o.portal,
+		Conduit:   o.conduit,
+		IP:        o.ip,
+		Taints:    o.taints,
+		Tracer:    o.tracer,
+		Tag:       o.tag,
+	}
+	if config.Token != "" {
+		return config, nil
+	}
+	if o.registrationToken == "" {
+		return nil, errors.New("a registration token or a conflux token is required")
+	}
+
+	request := &ResgitrationRequest{
+		RegistrationToken: o.registrationToken,
+		Guardian:          o.guardian,
+		Tag:               o.tag,
+	}
+	if o.idp != nil {
+		request.JWT = o.idp.JWT
+		request.JWKS_url = o.idp.JWKS_url
+		request.Audience = o.idp.Audience
+		request.Issuer = o.idp.Issuer
+	}
+	response, err := RegisterConflux(request)
+	if err != nil {
+		return nil, err
+	}
+	config.ConfluxID, config.Token = response.ConfluxID, response.Token
+	return config, nil
+}
+
+// watch ends the conflux when ctx is cancelled or the spawned anchor exits.
+func (c *Conflux) watch(ctx context.Context) {
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), anchorReadyTimeout)
+		defer cancel()
+		c.stop(stopCtx, ctx.Err())
+	case <-c.exited:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.stopping {
+			return
+		}
+		err := c.exitErr
+		if err == nil {
+			err = errors.New("anchor subprocess exited")
+		}
+		c.options.logger.Sugar().Errorf("%s", CrashReport(err))
+		c.finish(fmt.Errorf("anchor subprocess exited unexpectedly: %w", err))
+	}
+}
+
+// Stop stops the anchor, kills the spawned subprocess and waits until it exited.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the StopAnchor RPC and the wait for the subprocess.
+//
+// Outputs:
+//   - err: error. Non-nil if the conflux was not started or ctx ends before the subprocess exited.
+func (c *Conflux) Stop(ctx context.Context) error {
+	return c.stop(ctx, nil)
+}
+
+// stop implements Stop; cause is the error Err reports afterwards.
+func (c *Conflux) stop(ctx context.Context, cause error) error {
+	c.mu.Lock()
+	if !c.started {
+		c.mu.Unlock()
+		return errors.New("conflux not started")
+	}
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return nil
+	default:
+	}
+	c.stopping = true
+	client, subprocess, exited := c.client, c.subprocess, c.exited
+	c.mu.Unlock()
+
+	// Stop the anchor, then the subprocess
+	if client != nil {
+		if _, err := client.StopAnchor(ctx, &emptypb.Empty{}); err != nil {
+			c.options.logger.Sugar().Warnf("failed to stop anchor: %v", err)
+		}
+	}
+	var err error
+	if subprocess != nil {
+		subprocess.Process.Kill()
+		select {
+		case <-exited:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finish(cause)
+	return err
+}
+
+// finish records why the conflux ended, closes the gRPC connection and closes Done; the caller holds c.mu.
+func (c *Conflux) finish(err error) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.err = err
+	close(c.done)
+}
+
+// Info reads the conflux, realm, veil and tracer info of the running anchor.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the RPCs.
+//
+// Outputs:
+//   - *Snapshot. The snapshot.
+//   - err: error. Non-nil if the conflux is not running or any RPC fails.
+func (c *Conflux) Info(ctx context.Context) (*Snapshot, error) {
+	c.mu.Lock()
+	client := c.client
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+		return nil, errors.New("conflux not running")
+	default:
+	}
+	if client == nil {
+		return nil, errors.New("conflux not running")
+	}
+	return GetSnapshot(ctx, client)
+}
+
+// Config returns the config the conflux was started with, including its ID and token; nil before Start.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *ConfluxConfig. A copy of the config.
+func (c *Conflux) Config() *ConfluxConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.config == nil {
+		return nil
+	}
+	config := *c.config
+	return &config
+}
+
+// Done returns a channel closed once the conflux has ended.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - <-chan struct{}. Closed by Stop, cancellation of the Start context, an anchor exit or a failed Start.
+func (c *Conflux) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the conflux ended.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - err: error. Nil while running or after Stop; the context error after cancellation, the exit error after the
+//     anchor exited, or the startup error.
+func (c *Conflux) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// waitListening waits until address accepts TCP connections; gRPC clients connect lazily so dial explicitly.
+func waitListening(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, anchorReadyTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
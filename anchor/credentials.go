@@ -0,0 +1,137 @@
+package anchor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/veil-net/conflux/credential"
+)
+
+// CredentialStoreEnv selects where SaveConfig keeps the conflux token: auto (the default, the secure store of the OS
+// when usable) or plaintext (conflux.json).
+const CredentialStoreEnv = "VEILNET_CREDENTIAL_STORE"
+
+// CredentialStorePlaintext is the CredentialStoreEnv value keeping the token in conflux.json.
+const CredentialStorePlaintext = "plaintext"
+
+// OpenCredentialStore returns the secure store SaveConfig moves the token into.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - credential.Store. The secure store of the OS.
+//   - err: error. Wraps credential.ErrUnavailable if no secure store is usable or VEILNET_CREDENTIAL_STORE=plaintext.
+func OpenCredentialStore() (credential.Store, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return openCredentialStore(configDir)
+}
+
+// openCredentialStore returns the secure store in configDir, honouring CredentialStoreEnv.
+func openCredentialStore(configDir string) (credential.Store, error) {
+	switch mode := os.Getenv(CredentialStoreEnv); mode {
+	case "", "auto":
+		return credential.Open(configDir)
+	case CredentialStorePlaintext:
+		return nil, fmt.Errorf("%w: %s=%s", credential.ErrUnavailable, CredentialStoreEnv, mode)
+	default:
+		return nil, fmt.Errorf("%w: invalid %s %q, must be auto or plaintext", credential.ErrUnavailable, CredentialStoreEnv, mode)
+	}
+}
+
+// loadToken reads the token of config from the credential store named in the config.
+func loadToken(configDir string, config *ConfluxConfig) (string, error) {
+	store, err := credential.Open(configDir)
+	if err != nil {
+		return "", fmt.Errorf("the conflux token is in the %s credential store: %w", config.CredentialStore, err)
+	}
+	if store.Name() != config.CredentialStore {
+		return "", fmt.Errorf("the conflux token is in the %s credential store, this host uses %s", config.CredentialStore, store.Name())
+	}
+	return store.Load(config.ConfluxID)
+}
+
+// storeToken moves the token of config into the secure store and returns the config to write to conflux.json.
+// When no secure store is usable it warns and returns the config with the token in plaintext.
+func storeToken(configDir string, config *ConfluxConfig) *ConfluxConfig {
+	saved := *config
+	saved.CredentialStore = ""
+	if saved.Token == "" {
+		return &saved
+	}
+
+	store, err := openCredentialStore(configDir)
+	if err == nil {
+		if err = store.Save(saved.ConfluxID, saved.Token); err == nil {
+			saved.Token, saved.CredentialStore = "", store.Name()
+			return &saved
+		}
+		err = fmt.Errorf("failed to save the token in the %s credential store: %w", store.Name(), err)
+	}
+	if os.Getenv(CredentialStoreEnv) != CredentialStorePlaintext {
+		Logger.Sugar().Warnf("storing the conflux token in plaintext in conflux.json: %v", err)
+	}
+
+	// Drop the token the store may still hold from a previous save
+	if config.CredentialStore != "" {
+		deleteToken(configDir)
+	}
+	return &saved
+}
+
+// deleteToken removes the token recorded in conflux.json from the credential store, if any.
+func deleteToken(configDir string) {
+	data, err := os.ReadFile(filepath.Join(configDir, "conflux.json"))
+	if err != nil {
+		return
+	}
+	var config ConfluxConfig
+	if err := json.Unmarshal(data, &config); err != nil || config.CredentialStore == "" {
+		return
+	}
+	store, err := credential.Open(configDir)
+	if err != nil || store.Name() != config.CredentialStore {
+		return
+	}
+	if err := store.Delete(config.ConfluxID); err != nil && !errors.Is(err, credential.ErrNotFound) {
+		Logger.Sugar().Warnf("failed to delete the conflux token from the %s credential store: %v", store.Name(), err)
+	}
+}
+
+// MigrateCredentials moves a plaintext token in conflux.json into the secure store of the OS.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - string. The name of the store now holding the token.
+//   - err: error. Non-nil if the config cannot be read or written, or no secure store is usable.
+func MigrateCredentials() (string, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to load configuration: %v", err)
+		return "", err
+	}
+	if config.CredentialStore != "" {
+		return config.CredentialStore, nil
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if _, err := openCredentialStore(configDir); err != nil {
+		return "", err
+	}
+	if err := SaveConfig(config); err != nil {
+		Logger.Sugar().Errorf("failed to save configuration: %v", err)
+		return "", err
+	}
+	if config.CredentialStore == "" {
+		return "", errors.New("the token could not be moved into the credential store, it is still in conflux.json")
+	}
+	return config.CredentialStore, nil
+}
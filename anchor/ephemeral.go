@@ -0,0 +1,187 @@
+package anchor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ephemeralRecordPrefix starts the names of the cleanup record and lock files of ephemeral confluxes in the config
+// directory, e.g. ephemeral-<conflux ID>.json and ephemeral-<conflux ID>.lock.
+const ephemeralRecordPrefix = "ephemeral-"
+
+// ErrEphemeralInUse is returned by LockEphemeral when a running process holds the lock of the conflux.
+var ErrEphemeralInUse = errors.New("ephemeral conflux is in use by a running process")
+
+// EphemeralRecord is the cleanup record of an ephemeral conflux. It is written once the run holds the lock of the
+// conflux and removed once the conflux is unregistered, so a record whose lock is free was left behind by a crash.
+type EphemeralRecord struct {
+	ConfluxID string `json:"conflux_id"`
+	Guardian  string `json:"guardian"`
+	// Token is the conflux token, used to log the conflux out, which deletes a conflux that is not a portal.
+	Token string `json:"token"`
+	// Portal is set for a portal conflux, which is unregistered with RegistrationToken instead.
+	Portal            bool      `json:"portal,omitempty"`
+	RegistrationToken string    `json:"registration_token,omitempty"`
+	RegisteredAt      time.Time `json:"registered_at"`
+}
+
+// ephemeralPath returns the path of the record (ext ".json") or lock (ext ".lock") file of an ephemeral conflux.
+func ephemeralPath(confluxID string, ext string) (string, error) {
+	if confluxID == "" || strings.ContainsAny(confluxID, `/\`) || confluxID == "." || confluxID == ".." {
+		return "", fmt.Errorf("invalid conflux ID %q", confluxID)
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, ephemeralRecordPrefix+confluxID+ext), nil
+}
+
+// LockEphemeral takes the lock of an ephemeral conflux, held until release is called or the process exits. A run
+// holds it from before it writes the record until the conflux is unregistered; cleanup holds it while unregistering.
+//
+// Inputs:
+//   - confluxID: string. The ephemeral conflux.
+//
+// Outputs:
+//   - release: func(). Removes the lock file and releases the lock.
+//   - err: error. ErrEphemeralInUse if another process holds the lock, non-nil if the lock file cannot be opened.
+func LockEphemeral(confluxID string) (func(), error) {
+	path, err := ephemeralPath(confluxID, ".lock")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		os.Remove(path)
+		file.Close()
+	}, nil
+}
+
+// SaveEphemeralRecord writes the cleanup record of an ephemeral conflux; the caller holds its lock.
+//
+// Inputs:
+//   - record: *EphemeralRecord. The conflux to unregister if this run does not.
+//
+// Outputs:
+//   - err: error. Non-nil if the record cannot be written.
+func SaveEphemeralRecord(record *EphemeralRecord) error {
+	path, err := ephemeralPath(record.ConfluxID, ".json")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeConfigFile(path, data)
+}
+
+// LoadEphemeralRecord reads the cleanup record of an ephemeral conflux.
+//
+// Inputs:
+//   - confluxID: string. The ephemeral conflux.
+//
+// Outputs:
+//   - *EphemeralRecord. The record, nil if there is none.
+//   - err: error. Non-nil if the record cannot be read or is invalid.
+func LoadEphemeralRecord(confluxID string) (*EphemeralRecord, error) {
+	path, err := ephemeralPath(confluxID, ".json")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &EphemeralRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// EphemeralConfluxes lists the ephemeral confluxes with a cleanup record, whether their run is alive or not.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - []string. The conflux IDs.
+//   - err: error. Non-nil if the config directory cannot be read.
+func EphemeralConfluxes() ([]string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(configDir, ephemeralRecordPrefix+"*.json"))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(matches))
+	for _, match := range matches {
+		name := filepath.Base(match)
+		ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(name, ephemeralRecordPrefix), ".json"))
+	}
+	return ids, nil
+}
+
+// DeleteEphemeralRecord removes the cleanup record once its conflux is unregistered.
+//
+// Inputs:
+//   - confluxID: string. The ephemeral conflux.
+//
+// Outputs:
+//   - err: error. Non-nil if the record exists and cannot be removed.
+func DeleteEphemeralRecord(confluxID string) error {
+	path, err := ephemeralPath(confluxID, ".json")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// UnregisterEphemeral unregisters the conflux of the cleanup record with the credential it holds and removes the
+// record: a portal is unregistered with the registration token, any other conflux is logged out with its conflux
+// token. A conflux the Guardian no longer knows counts as unregistered.
+//
+// Inputs:
+//   - record: *EphemeralRecord. The conflux to unregister.
+//
+// Outputs:
+//   - err: error. Non-nil if the guardian request fails or the record cannot be removed; the record is kept then.
+func UnregisterEphemeral(record *EphemeralRecord) error {
+	var err error
+	if record.Portal {
+		err = UnregisterConflux(record.RegistrationToken, &ConfluxConfig{Guardian: record.Guardian, ConfluxID: record.ConfluxID})
+	} else {
+		err = LogoutConflux(record.Guardian, record.Token)
+	}
+	if err != nil && !errors.Is(err, ErrConfluxNotFound) {
+		return err
+	}
+	return DeleteEphemeralRecord(record.ConfluxID)
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package anchor
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on file without waiting, released when the file is closed.
+//
+// Inputs:
+//   - file: *os.File. The open lock file.
+//
+// Outputs:
+//   - err: error. ErrEphemeralInUse if another open file holds the lock.
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrEphemeralInUse
+	}
+	return err
+}
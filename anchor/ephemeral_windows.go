@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package anchor
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes an exclusive lock on file without waiting, released when the file is closed.
+//
+// Inputs:
+//   - file: *os.File. The open lock file.
+//
+// Outputs:
+//   - err: error. ErrEphemeralInUse if another open file holds the lock.
+func lockFile(file *os.File) error {
+	overlapped := &windows.Overlapped{}
+	err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return ErrEphemeralInUse
+	}
+	return err
+}
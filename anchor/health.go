@@ -0,0 +1,162 @@
+package anchor
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Health check exit codes, one per check so scripts and orchestrators can tell failures apart.
+const (
+	HealthOK                = 0
+	HealthAnchorUnreachable = 2
+	HealthInfoFailed        = 3
+	HealthRealmUnassigned   = 4
+	HealthVeilDisconnected  = 5
+	HealthTethersBelowMin   = 6
+)
+
+// HealthCheck is the result of a single health check.
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+}
+
+// HealthReport is the result of a health check run; checks stop at the first failure.
+type HealthReport struct {
+	Healthy bool          `json:"healthy"`
+	Code    int           `json:"code"`
+	Checks  []HealthCheck `json:"checks"`
+}
+
+// HealthError is returned for an unhealthy report; its ExitCode is the code of the failed check.
+type HealthError struct {
+	Check  string
+	Code   int
+	Detail string
+}
+
+// Error returns the failed check and its detail.
+func (e *HealthError) Error() string {
+	return fmt.Sprintf("health check %s failed: %s", e.Check, e.Detail)
+}
+
+// ExitCode returns the exit code of the failed check.
+func (e *HealthError) ExitCode() int {
+	return e.Code
+}
+
+// Err returns nil for a healthy report, or a *HealthError describing the failed check.
+//
+// Inputs:
+//   - r: *HealthReport. The report.
+//
+// Outputs:
+//   - err: error. Nil if healthy, otherwise a *HealthError.
+func (r *HealthReport) Err() error {
+	if r.Healthy {
+		return nil
+	}
+	last := r.Checks[len(r.Checks)-1]
+	return &HealthError{Check: last.Name, Code: r.Code, Detail: last.Detail}
+}
+
+// pass records a passed check.
+func (r *HealthReport) pass(name, detail string) {
+	r.Checks = append(r.Checks, HealthCheck{Name: name, OK: true, Detail: detail})
+}
+
+// fail records a failed check and marks the report unhealthy with code.
+func (r *HealthReport) fail(name string, code int, detail string) *HealthReport {
+	r.Checks = append(r.Checks, HealthCheck{Name: name, OK: false, Detail: detail})
+	r.Healthy = false
+	r.Code = code
+	return r
+}
+
+// CheckLiveness checks that the anchor is reachable and answers GetInfo.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the checks.
+//   - client: pb.AnchorClient. The anchor client; nil counts as unreachable.
+//
+// Outputs:
+//   - *HealthReport. The report; see Err for the failure.
+func CheckLiveness(ctx context.Context, client pb.AnchorClient) *HealthReport {
+	report, _ := checkAnchor(ctx, client)
+	return report
+}
+
+// CheckHealth checks that the anchor is reachable, answers GetInfo, has a realm assigned, is connected to
+// a veil and has at least minTethers tethers.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the checks.
+//   - client: pb.AnchorClient. The anchor client; nil counts as unreachable.
+//   - minTethers: int. The minimum number of tethers; 0 disables the check.
+//
+// Outputs:
+//   - *HealthReport. The report; see Err for the failure.
+func CheckHealth(ctx context.Context, client pb.AnchorClient, minTethers int) *HealthReport {
+	report, info := checkAnchor(ctx, client)
+	if !report.Healthy {
+		return report
+	}
+
+	// Check the realm
+	realm, err := client.GetRealmInfo(ctx, &emptypb.Empty{})
+	if err != nil {
+		return report.fail("realm", HealthRealmUnassigned, err.Error())
+	}
+	if realm.GetRealmId() == "" {
+		return report.fail("realm", HealthRealmUnassigned, "no realm assigned")
+	}
+	report.pass("realm", fmt.Sprintf("%s (%s)", realm.GetRealm(), realm.GetRealmId()))
+
+	// Check the veil
+	veil, err := client.GetVeilInfo(ctx, &emptypb.Empty{})
+	if err != nil {
+		return report.fail("veil", HealthVeilDisconnected, err.Error())
+	}
+	if veil.GetVeilHost() == "" {
+		return report.fail("veil", HealthVeilDisconnected, "not connected to a veil")
+	}
+	report.pass("veil", fmt.Sprintf("%s:%d (%s)", veil.GetVeilHost(), veil.GetVeilPort(), veil.GetRegion()))
+
+	// Check the tethers
+	tethers := int(info.GetNumberOfTethers())
+	if tethers < minTethers {
+		return report.fail("tethers", HealthTethersBelowMin, fmt.Sprintf("%d tethers, want at least %d", tethers, minTethers))
+	}
+	report.pass("tethers", fmt.Sprintf("%d tethers", tethers))
+	return report
+}
+
+// checkAnchor runs the reachability and GetInfo checks shared by CheckLiveness and CheckHealth.
+func checkAnchor(ctx context.Context, client pb.AnchorClient) (*HealthReport, *pb.GetInfoResponse) {
+	report := &HealthReport{Healthy: true, Code: HealthOK}
+
+	// Check the anchor is listening; gRPC clients connect lazily so dial explicitly
+	if client == nil {
+		return report.fail("anchor", HealthAnchorUnreachable, "anchor is not running"), nil
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", Address())
+	if err != nil {
+		return report.fail("anchor", HealthAnchorUnreachable, err.Error()), nil
+	}
+	conn.Close()
+	report.pass("anchor", "reachable at "+Address())
+
+	// Check the anchor answers
+	info, err := client.GetInfo(ctx, &emptypb.Empty{})
+	if err != nil {
+		return report.fail("info", HealthInfoFailed, err.Error()), nil
+	}
+	report.pass("info", fmt.Sprintf("conflux %s (%s)", info.GetId(), info.GetTag()))
+	return report, info
+}
@@ -0,0 +1,539 @@
+package anchor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultLogRingSize is the number of recent anchor log lines kept in memory.
+const DefaultLogRingSize = 1000
+
+// LogLine is a single parsed line of anchor subprocess output.
+type LogLine struct {
+	Time    time.Time      `json:"time"`
+	Stream  string         `json:"stream"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// String formats the line as a single human-readable line.
+//
+// Inputs:
+//   - l: LogLine. The line to format.
+//
+// Outputs:
+//   - string. Time, level, stream, message and fields separated by spaces.
+func (l LogLine) String() string {
+	var b strings.Builder
+	b.WriteString(l.Time.Format(time.RFC3339))
+	b.WriteString(" ")
+	b.WriteString(strings.ToUpper(l.Level))
+	b.WriteString(" [")
+	b.WriteString(l.Stream)
+	b.WriteString("] ")
+	b.WriteString(l.Message)
+	if len(l.Fields) > 0 {
+		fields, err := json.Marshal(l.Fields)
+		if err == nil {
+			b.WriteString(" ")
+			b.Write(fields)
+		}
+	}
+	return b.String()
+}
+
+// LogRing is a bounded ring buffer of recent anchor log lines, safe for concurrent use.
+type LogRing struct {
+	mu    sync.Mutex
+	lines []LogLine
+	next  int
+	full  bool
+}
+
+// NewLogRing returns a ring buffer that keeps at most size lines.
+//
+// Inputs:
+//   - size: int. The maximum number of lines kept; values below 1 are treated as 1.
+//
+// Outputs:
+//   - *LogRing. An empty ring buffer.
+func NewLogRing(size int) *LogRing {
+	if size < 1 {
+		size = 1
+	}
+	return &LogRing{lines: make([]LogLine, size)}
+}
+
+// Add appends a line, overwriting the oldest line once the ring is full.
+//
+// Inputs:
+//   - line: LogLine. The line to keep.
+//
+// Outputs: none.
+func (r *LogRing) Add(line LogLine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines returns a copy of all kept lines, oldest first.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - []LogLine. The kept lines in chronological order.
+func (r *LogRing) Lines() []LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]LogLine(nil), r.lines[:r.next]...)
+	}
+	return append(append([]LogLine(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// Tail returns a copy of the last n kept lines, oldest first.
+//
+// Inputs:
+//   - n: int. The maximum number of lines to return; n <= 0 returns all lines.
+//
+// Outputs:
+//   - []LogLine. The most recent lines in chronological order.
+func (r *LogRing) Tail(n int) []LogLine {
+	lines := r.Lines()
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// AnchorLogs holds the recent output of the anchor subprocess started by this process.
+var AnchorLogs = NewLogRing(DefaultLogRingSize)
+
+// GetLogFilePath returns the path of the file the anchor log ring is persisted to.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - string. The path of anchor.log in the config directory.
+//   - err: error. Non-nil if the config directory cannot be determined.
+func GetLogFilePath() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "anchor.log"), nil
+}
+
+// SaveLogs writes the anchor log ring to the log file as JSON lines, replacing its previous content.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - err: error. Non-nil if the file cannot be written.
+func SaveLogs() error {
+	logFilePath, err := GetLogFilePath()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, line := range AnchorLogs.Lines() {
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(logFilePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(logFilePath, buf.Bytes(), 0644)
+}
+
+// LoadLogs reads the anchor log lines persisted by the running conflux.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - []LogLine. The persisted lines, oldest first.
+//   - err: error. Non-nil if the file is missing or cannot be read.
+func LoadLogs() ([]LogLine, error) {
+	logFilePath, err := GetLogFilePath()
+	if err != nil {
+		return nil, err
+	}
+	logFile, err := os.Open(logFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+
+	lines := []LogLine{}
+	scanner := bufio.NewScanner(logFile)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line LogLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// saveLogsDelay bounds how often the log ring is persisted while the anchor is writing.
+const saveLogsDelay = time.Second
+
+var (
+	saveLogsMu      sync.Mutex
+	saveLogsPending bool
+)
+
+// scheduleSaveLogs persists the log ring after saveLogsDelay unless a save is already pending.
+func scheduleSaveLogs() {
+	saveLogsMu.Lock()
+	defer saveLogsMu.Unlock()
+	if saveLogsPending {
+		return
+	}
+	saveLogsPending = true
+	time.AfterFunc(saveLogsDelay, func() {
+		saveLogsMu.Lock()
+		saveLogsPending = false
+		saveLogsMu.Unlock()
+		_ = SaveLogs()
+	})
+}
+
+// logWriter splits subprocess output into lines and forwards each one through handleLogLine.
+type logWriter struct {
+	mu     sync.Mutex
+	stream string
+	logger *zap.Logger
+	buf    []byte
+}
+
+// newLogWriter returns an io.Writer to be used as the stdout or stderr of the anchor subprocess, re-emitting its
+// lines through logger.
+func newLogWriter(stream string, logger *zap.Logger) *logWriter {
+	return &logWriter{stream: stream, logger: logger}
+}
+
+// Write buffers p and handles every complete line in it.
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(w.buf[:i]), "\r")
+		w.buf = w.buf[i+1:]
+		if strings.TrimSpace(line) != "" {
+			handleLogLine(w.logger, w.stream, line)
+		}
+	}
+	return len(p), nil
+}
+
+// handleLogLine parses a raw line, keeps it in the ring and re-emits it through logger.
+func handleLogLine(logger *zap.Logger, stream string, raw string) {
+	line := ParseLogLine(stream, raw)
+	AnchorLogs.Add(line)
+	scheduleSaveLogs()
+
+	level, err := zapcore.ParseLevel(line.Level)
+	if err != nil {
+		level = zapcore.InfoLevel
+	}
+	// Never let anchor output terminate the wrapper
+	if level > zapcore.ErrorLevel {
+		level = zapcore.ErrorLevel
+	}
+	if entry := logger.Check(level, line.Message); entry != nil {
+		fields := make([]zap.Field, 0, len(line.Fields)+2)
+		fields = append(fields, zap.String("component", "anchor"), zap.String("stream", line.Stream))
+		for key, value := range line.Fields {
+			fields = append(fields, zap.Any(key, value))
+		}
+		entry.Write(fields...)
+	}
+}
+
+var (
+	// ansiPattern matches ANSI color escape sequences.
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// plainPattern matches "<timestamp> <LEVEL> <message>" lines (Go log, tracing and similar formats).
+	plainPattern = regexp.MustCompile(`^(\d{4}[-/]\d{2}[-/]\d{2}[T ][0-9:.,]+(?:Z|[+-]\d{2}:?\d{2})?)\s+\[?(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|PANIC|DPANIC)\]?:?\s+(.*)$`)
+	// logfmtPattern matches key=value and key="quoted value" pairs.
+	logfmtPattern = regexp.MustCompile(`([\w.\-]+)=("(?:[^"\\]|\\.)*"|\S+)`)
+)
+
+// ParseLogLine parses a raw anchor output line into a LogLine.
+//
+// Recognised formats are JSON objects (zap production and similar), zap console lines (tab separated),
+// logfmt (level=... msg=...), and "<timestamp> <LEVEL> <message>" lines. Anything else is kept verbatim
+// at info level for stdout and warn level for stderr.
+//
+// Inputs:
+//   - stream: string. The stream the line was read from ("stdout" or "stderr").
+//   - raw: string. The raw line without its trailing newline.
+//
+// Outputs:
+//   - LogLine. The parsed line; Time is the current time when the line carries none.
+func ParseLogLine(stream string, raw string) LogLine {
+	text := strings.TrimSpace(ansiPattern.ReplaceAllString(raw, ""))
+	line := LogLine{
+		Time:    time.Now(),
+		Stream:  stream,
+		Level:   "info",
+		Message: text,
+	}
+	if stream == "stderr" {
+		line.Level = "warn"
+	}
+
+	switch {
+	case parseJSONLogLine(text, &line):
+	case parseConsoleLogLine(text, &line):
+	case parseLogfmtLine(text, &line):
+	case parsePlainLogLine(text, &line):
+	}
+	return line
+}
+
+// parseJSONLogLine parses structured JSON log lines.
+func parseJSONLogLine(text string, line *LogLine) bool {
+	if !strings.HasPrefix(text, "{") {
+		return false
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		return false
+	}
+	for _, key := range []string{"level", "lvl", "severity"} {
+		if value, ok := fields[key].(string); ok {
+			line.Level = normalizeLevel(value)
+			delete(fields, key)
+			break
+		}
+	}
+	for _, key := range []string{"msg", "message"} {
+		if value, ok := fields[key].(string); ok {
+			line.Message = value
+			delete(fields, key)
+			break
+		}
+	}
+	for _, key := range []string{"ts", "time", "timestamp"} {
+		if value, ok := fields[key]; ok {
+			if t, ok := parseLogTime(value); ok {
+				line.Time = t
+			}
+			delete(fields, key)
+			break
+		}
+	}
+	if len(fields) > 0 {
+		line.Fields = fields
+	}
+	return true
+}
+
+// parseConsoleLogLine parses zap console lines: "<ts>\t<LEVEL>\t[<caller>\t]<msg>[\t<json fields>]".
+func parseConsoleLogLine(text string, line *LogLine) bool {
+	parts := strings.Split(text, "\t")
+	if len(parts) < 3 {
+		return false
+	}
+	t, ok := parseLogTime(parts[0])
+	if !ok || !isLevel(parts[1]) {
+		return false
+	}
+	line.Time = t
+	line.Level = normalizeLevel(parts[1])
+	rest := parts[2:]
+	if last := rest[len(rest)-1]; len(rest) > 1 && strings.HasPrefix(last, "{") {
+		fields := map[string]any{}
+		if err := json.Unmarshal([]byte(last), &fields); err == nil {
+			line.Fields = fields
+			rest = rest[:len(rest)-1]
+		}
+	}
+	// A caller column looks like "pkg/file.go:123"
+	if len(rest) > 1 && strings.Contains(rest[0], ".go:") {
+		if line.Fields == nil {
+			line.Fields = map[string]any{}
+		}
+		line.Fields["caller"] = rest[0]
+		rest = rest[1:]
+	}
+	line.Message = strings.Join(rest, " ")
+	return true
+}
+
+// parseLogfmtLine parses logfmt lines carrying at least a level and a message key.
+func parseLogfmtLine(text string, line *LogLine) bool {
+	if !strings.Contains(text, "level=") && !strings.Contains(text, "lvl=") {
+		return false
+	}
+	matches := logfmtPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return false
+	}
+	fields := map[string]any{}
+	parsed := LogLine{Time: line.Time, Stream: line.Stream, Level: line.Level, Message: ""}
+	for _, match := range matches {
+		key, value := match[1], match[2]
+		if strings.HasPrefix(value, `"`) {
+			if unquoted, err := unquoteLogfmt(value); err == nil {
+				value = unquoted
+			}
+		}
+		switch key {
+		case "level", "lvl":
+			parsed.Level = normalizeLevel(value)
+		case "msg", "message":
+			parsed.Message = value
+		case "ts", "time", "timestamp":
+			if t, ok := parseLogTime(value); ok {
+				parsed.Time = t
+			}
+		default:
+			fields[key] = value
+		}
+	}
+	if parsed.Message == "" {
+		return false
+	}
+	if len(fields) > 0 {
+		parsed.Fields = fields
+	}
+	*line = parsed
+	return true
+}
+
+// parsePlainLogLine parses "<timestamp> <LEVEL> <message>" lines.
+func parsePlainLogLine(text string, line *LogLine) bool {
+	match := plainPattern.FindStringSubmatch(text)
+	if match == nil {
+		return false
+	}
+	if t, ok := parseLogTime(match[1]); ok {
+		line.Time = t
+	}
+	line.Level = normalizeLevel(match[2])
+	line.Message = match[3]
+	return true
+}
+
+// unquoteLogfmt removes the quotes and escapes of a quoted logfmt value.
+func unquoteLogfmt(value string) (string, error) {
+	var unquoted string
+	err := json.Unmarshal([]byte(value), &unquoted)
+	return unquoted, err
+}
+
+// parseLogTime parses RFC3339-like strings and unix epoch seconds.
+func parseLogTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case float64:
+		sec := int64(v)
+		return time.Unix(sec, int64((v-float64(sec))*1e9)), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700", "2006-01-02T15:04:05Z0700", "2006-01-02 15:04:05.000", "2006-01-02 15:04:05", "2006/01/02 15:04:05"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// isLevel reports whether s names a log level.
+func isLevel(s string) bool {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "TRACE", "DEBUG", "INFO", "WARN", "WARNING", "ERROR", "DPANIC", "PANIC", "FATAL":
+		return true
+	}
+	return false
+}
+
+// normalizeLevel maps level names to zap level names.
+func normalizeLevel(s string) string {
+	switch level := strings.ToLower(strings.TrimSpace(s)); level {
+	case "trace":
+		return "debug"
+	case "warning":
+		return "warn"
+	case "critical":
+		return "error"
+	default:
+		if _, err := zapcore.ParseLevel(level); err != nil {
+			return "info"
+		}
+		return level
+	}
+}
+
+// WaitAnchor waits for the anchor subprocess in the background.
+//
+// Inputs:
+//   - cmd: *exec.Cmd. The started anchor subprocess.
+//
+// Outputs:
+//   - <-chan error. Receives the result of cmd.Wait once the subprocess exits.
+func WaitAnchor(cmd *exec.Cmd) <-chan error {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	return exited
+}
+
+// CrashReport formats an unexpected anchor exit together with the most recent anchor log lines.
+//
+// Inputs:
+//   - err: error. The error the subprocess exited with, if any.
+//
+// Outputs:
+//   - string. A multi-line report suitable for logging.
+func CrashReport(err error) string {
+	var b strings.Builder
+	if err != nil {
+		fmt.Fprintf(&b, "anchor subprocess exited unexpectedly: %v", err)
+	} else {
+		b.WriteString("anchor subprocess exited unexpectedly")
+	}
+	lines := AnchorLogs.Tail(50)
+	if len(lines) > 0 {
+		b.WriteString("\nrecent anchor logs:")
+		for _, line := range lines {
+			b.WriteString("\n  ")
+			b.WriteString(line.String())
+		}
+	}
+	if err := SaveLogs(); err == nil {
+		if logFilePath, err := GetLogFilePath(); err == nil {
+			fmt.Fprintf(&b, "\nanchor logs saved to %s", logFilePath)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package anchor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseLogLine(t *testing.T) {
+	stamp := time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name    string
+		stream  string
+		raw     string
+		level   string
+		message string
+		time    time.Time
+		fields  map[string]any
+	}{
+		{
+			name:    "zap JSON",
+			stream:  "stderr",
+			raw:     `{"level":"error","ts":1714566645,"msg":"dial failed","peer":"a1"}`,
+			level:   "error",
+			message: "dial failed",
+			time:    stamp,
+			fields:  map[string]any{"peer": "a1"},
+		},
+		{
+			name:    "JSON with severity and message keys",
+			stream:  "stdout",
+			raw:     `{"severity":"WARNING","time":"2024-05-01T12:30:45Z","message":"slow"}`,
+			level:   "warn",
+			message: "slow",
+			time:    stamp,
+		},
+		{
+			name:    "zap console with caller and fields",
+			stream:  "stderr",
+			raw:     "2024-05-01T12:30:45.000Z\tINFO\tanchor/relay.go:42\trelay up\t{\"port\":443}",
+			level:   "info",
+			message: "relay up",
+			time:    stamp,
+			fields:  map[string]any{"caller": "anchor/relay.go:42", "port": float64(443)},
+		},
+		{
+			name:    "zap console with colors",
+			stream:  "stdout",
+			raw:     "2024-05-01T12:30:45.000Z\t\x1b[35mDEBUG\x1b[0m\thandshake",
+			level:   "debug",
+			message: "handshake",
+			time:    stamp,
+		},
+		{
+			name:    "logfmt",
+			stream:  "stdout",
+			raw:     `time=2024-05-01T12:30:45Z level=warning msg="peer lost" peer=b2`,
+			level:   "warn",
+			message: "peer lost",
+			time:    stamp,
+			fields:  map[string]any{"peer": "b2"},
+		},
+		{
+			name:    "plain timestamp and level",
+			stream:  "stdout",
+			raw:     "2024/05/01 12:30:45 [ERROR] tun closed",
+			level:   "error",
+			message: "tun closed",
+			time:    stamp,
+		},
+		{
+			name:    "trace level",
+			stream:  "stdout",
+			raw:     "2024-05-01 12:30:45 TRACE packet in",
+			level:   "debug",
+			message: "packet in",
+			time:    stamp,
+		},
+		{
+			name:    "unstructured stdout",
+			stream:  "stdout",
+			raw:     "starting anchor",
+			level:   "info",
+			message: "starting anchor",
+		},
+		{
+			name:    "unstructured stderr",
+			stream:  "stderr",
+			raw:     "panic: runtime error\r",
+			level:   "warn",
+			message: "panic: runtime error",
+		},
+		{
+			name:    "logfmt without message",
+			stream:  "stdout",
+			raw:     "level=info",
+			level:   "info",
+			message: "level=info",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := ParseLogLine(tt.stream, tt.raw)
+			if line.Stream != tt.stream {
+				t.Errorf("stream = %q, want %q", line.Stream, tt.stream)
+			}
+			if line.Level != tt.level {
+				t.Errorf("level = %q, want %q", line.Level, tt.level)
+			}
+			if line.Message != tt.message {
+				t.Errorf("message = %q, want %q", line.Message, tt.message)
+			}
+			if !tt.time.IsZero() && !line.Time.Equal(tt.time) {
+				t.Errorf("time = %s, want %s", line.Time, tt.time)
+			}
+			if !reflect.DeepEqual(line.Fields, tt.fields) && (len(line.Fields) > 0 || len(tt.fields) > 0) {
+				t.Errorf("fields = %v, want %v", line.Fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestLogRing(t *testing.T) {
+	ring := NewLogRing(3)
+	for i := range 5 {
+		ring.Add(LogLine{Message: fmt.Sprint(i)})
+	}
+	var messages []string
+	for _, line := range ring.Lines() {
+		messages = append(messages, line.Message)
+	}
+	if want := []string{"2", "3", "4"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("lines = %v, want %v", messages, want)
+	}
+	if tail := ring.Tail(1); len(tail) != 1 || tail[0].Message != "4" {
+		t.Errorf("tail = %v, want the last line", tail)
+	}
+}
+
+func TestLogWriterForwardsToLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	writer := newLogWriter("stderr", zap.New(core))
+
+	// Lines may be split across writes
+	fmt.Fprint(writer, `{"level":"fatal","msg":"out of`)
+	fmt.Fprint(writer, " memory\"}\nplain line\n\npartial")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	// A fatal line from the anchor is capped at error so it cannot exit the wrapper
+	if entries[0].Level != zapcore.ErrorLevel || entries[0].Message != "out of memory" {
+		t.Errorf("first entry = %s %q, want error %q", entries[0].Level, entries[0].Message, "out of memory")
+	}
+	if entries[1].Level != zapcore.WarnLevel || entries[1].Message != "plain line" {
+		t.Errorf("second entry = %s %q, want warn %q", entries[1].Level, entries[1].Message, "plain line")
+	}
+	if fields := entries[1].ContextMap(); fields["component"] != "anchor" || fields["stream"] != "stderr" {
+		t.Errorf("fields = %v, want the anchor component and stderr stream", fields)
+	}
+}
@@ -0,0 +1,88 @@
+package anchor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/veil-net/conflux/guardian"
+)
+
+// GuardianConflux is a conflux as listed by the Guardian.
+type GuardianConflux struct {
+	ID     string `json:"id"`
+	Tag    string `json:"tag"`
+	CIDR   string `json:"cidr"`
+	Subnet string `json:"subnet"`
+	Plane  string `json:"plane"`
+	Portal bool   `json:"portal"`
+	Region string `json:"region"`
+}
+
+// ListConfluxes lists the confluxes the user owns or can see in their realms.
+//
+// Inputs:
+//   - guardianURL: string. The Guardian URL.
+//   - accessToken: string. An access token of the user (not a registration or conflux token).
+//
+// Outputs:
+//   - []GuardianConflux. The confluxes.
+//   - err: error. Non-nil if the guardian request fails.
+func ListConfluxes(guardianURL string, accessToken string) ([]GuardianConflux, error) {
+	// Create the request
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/conflux/list", guardianURL), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	// Make the request
+	resp, err := guardian.Default().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list confluxes: %s: %s", resp.Status, string(body))
+	}
+
+	// Parse the response body
+	var confluxes []GuardianConflux
+	if err := json.Unmarshal(body, &confluxes); err != nil {
+		return nil, err
+	}
+	return confluxes, nil
+}
+
+// FindConflux looks up the conflux registered with tag.
+//
+// Inputs:
+//   - guardianURL: string. The Guardian URL.
+//   - accessToken: string. An access token of the user.
+//   - tag: string. The tag of the conflux.
+//
+// Outputs:
+//   - *GuardianConflux. The conflux, nil if none has the tag.
+//   - err: error. Non-nil if the guardian request fails or several confluxes have the tag.
+func FindConflux(guardianURL string, accessToken string, tag string) (*GuardianConflux, error) {
+	confluxes, err := ListConfluxes(guardianURL, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	var found *GuardianConflux
+	for i := range confluxes {
+		if confluxes[i].Tag != tag {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("several confluxes are tagged %q (%s, %s)", tag, found.ID, confluxes[i].ID)
+		}
+		found = &confluxes[i]
+	}
+	return found, nil
+}
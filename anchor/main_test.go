@@ -0,0 +1,40 @@
+package anchor
+
+import (
+	"os"
+	"testing"
+
+	"github.com/veil-net/conflux/anchortest"
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/grpc"
+)
+
+// TestMain points the config directory at a temporary directory, so tests never touch the config of the host.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "conflux-anchor-test")
+	if err != nil {
+		panic(err)
+	}
+	SetConfigDir(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// startFakeAnchor serves a fake anchor for the test, points Address at it and returns it with a client.
+func startFakeAnchor(t *testing.T) (*anchortest.Server, pb.AnchorClient) {
+	t.Helper()
+	server := anchortest.NewServer()
+	address, err := server.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	t.Setenv(AnchorAddressEnv, address)
+	conn, err := grpc.NewClient(address, dialOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return server, pb.NewAnchorClient(conn)
+}
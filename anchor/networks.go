@@ -0,0 +1,184 @@
+package anchor
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/veil-net/conflux/guardian"
+	pb "github.com/veil-net/conflux/proto"
+)
+
+// Outcomes of the cross-check of a network with the Guardian.
+const (
+	NetworkAgreed       = "ok"
+	NetworkAnchorOnly   = "anchor only"
+	NetworkGuardianOnly = "guardian only"
+)
+
+// Network is a subnet advertised by the conflux, or reachable through the peer with the signature.
+type Network struct {
+	Subnet        string `json:"subnet"`
+	PeerSignature string `json:"peer_signature,omitempty"`
+	// Status is the outcome of the cross-check with the Guardian, empty when not cross-checked.
+	Status string `json:"status,omitempty"`
+}
+
+// GuardianLocalNetworks fetches the subnets the Guardian knows the conflux advertises.
+//
+// Inputs:
+//   - guardianURL: string. The Guardian URL.
+//   - accessToken: string. An access token of the user owning the conflux.
+//   - confluxID: string. The conflux.
+//
+// Outputs:
+//   - *pb.LocalNetworks. The local networks.
+//   - err: error. Non-nil if the guardian request fails or the response is invalid.
+func GuardianLocalNetworks(guardianURL string, accessToken string, confluxID string) (*pb.LocalNetworks, error) {
+	found, err := getNetworks(guardianURL, "/conflux/local-network", accessToken, confluxID)
+	if err != nil {
+		return nil, err
+	}
+	networks := &pb.LocalNetworks{}
+	for _, network := range found {
+		networks.LocalNetworks = append(networks.LocalNetworks, &pb.LocalNetwork{Subnet: network.Subnet})
+	}
+	return networks, nil
+}
+
+// GuardianRemoteNetworks fetches the subnets the Guardian knows the conflux reaches through its peers.
+//
+// Inputs:
+//   - guardianURL: string. The Guardian URL.
+//   - accessToken: string. An access token of the user owning the conflux.
+//   - confluxID: string. The conflux.
+//
+// Outputs:
+//   - *pb.RemoteNetworks. The remote networks.
+//   - err: error. Non-nil if the guardian request fails or the response is invalid.
+func GuardianRemoteNetworks(guardianURL string, accessToken string, confluxID string) (*pb.RemoteNetworks, error) {
+	found, err := getNetworks(guardianURL, "/conflux/remote-network", accessToken, confluxID)
+	if err != nil {
+		return nil, err
+	}
+	networks := &pb.RemoteNetworks{}
+	for _, network := range found {
+		networks.RemoteNetworks = append(networks.RemoteNetworks, &pb.RemoteNetwork{PeerSignature: network.PeerSignature, Subnet: network.Subnet})
+	}
+	return networks, nil
+}
+
+// guardianNetwork is a network as listed by the Guardian.
+type guardianNetwork struct {
+	Subnet        string `json:"subnet"`
+	PeerSignature string `json:"peer_signature"`
+}
+
+// getNetworks fetches the list of networks of a network endpoint of the Guardian.
+func getNetworks(guardianURL string, path string, accessToken string, confluxID string) ([]guardianNetwork, error) {
+	// Create the request
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s?conflux_id=%s", guardianURL, path, url.QueryEscape(confluxID)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	// Make the request
+	resp, err := guardian.Default().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %s: %s: %s", path, resp.Status, string(body))
+	}
+
+	// Parse the response body
+	var networks []guardianNetwork
+	if err := json.Unmarshal(body, &networks); err != nil {
+		return nil, fmt.Errorf("invalid %s response: %w", path, err)
+	}
+	return networks, nil
+}
+
+// CompareLocalNetworks lists the local networks of the anchor, cross-checked with the view of the Guardian.
+//
+// Inputs:
+//   - anchor: *pb.LocalNetworks. The local networks reported by the anchor.
+//   - guardian: *pb.LocalNetworks. The local networks known to the Guardian, nil to skip the cross-check.
+//
+// Outputs:
+//   - []Network. The networks sorted by subnet, with the networks only the Guardian knows.
+func CompareLocalNetworks(anchor *pb.LocalNetworks, guardian *pb.LocalNetworks) []Network {
+	var seen, known []Network
+	for _, network := range anchor.GetLocalNetworks() {
+		seen = append(seen, Network{Subnet: network.GetSubnet()})
+	}
+	for _, network := range guardian.GetLocalNetworks() {
+		known = append(known, Network{Subnet: network.GetSubnet()})
+	}
+	return compareNetworks(seen, known, guardian != nil)
+}
+
+// CompareRemoteNetworks lists the remote networks of the anchor, cross-checked with the view of the Guardian; a
+// network matches when both the subnet and the peer signature agree.
+//
+// Inputs:
+//   - anchor: *pb.RemoteNetworks. The remote networks reported by the anchor.
+//   - guardian: *pb.RemoteNetworks. The remote networks known to the Guardian, nil to skip the cross-check.
+//
+// Outputs:
+//   - []Network. The networks sorted by subnet and peer signature, with the networks only the Guardian knows.
+func CompareRemoteNetworks(anchor *pb.RemoteNetworks, guardian *pb.RemoteNetworks) []Network {
+	var seen, known []Network
+	for _, network := range anchor.GetRemoteNetworks() {
+		seen = append(seen, Network{Subnet: network.GetSubnet(), PeerSignature: network.GetPeerSignature()})
+	}
+	for _, network := range guardian.GetRemoteNetworks() {
+		known = append(known, Network{Subnet: network.GetSubnet(), PeerSignature: network.GetPeerSignature()})
+	}
+	return compareNetworks(seen, known, guardian != nil)
+}
+
+// compareNetworks merges the networks seen by the anchor with those known to the Guardian, setting their status when
+// checked; networks match on the subnet and the peer signature.
+func compareNetworks(seen []Network, known []Network, checked bool) []Network {
+	var networks []Network
+	for _, network := range seen {
+		if !slices.Contains(networks, network) {
+			networks = append(networks, network)
+		}
+	}
+	if checked {
+		for i := range networks {
+			networks[i].Status = NetworkAnchorOnly
+			if slices.Contains(known, Network{Subnet: networks[i].Subnet, PeerSignature: networks[i].PeerSignature}) {
+				networks[i].Status = NetworkAgreed
+			}
+		}
+		for _, network := range known {
+			if slices.Contains(seen, network) {
+				continue
+			}
+			network.Status = NetworkGuardianOnly
+			if !slices.Contains(networks, network) {
+				networks = append(networks, network)
+			}
+		}
+	}
+	slices.SortFunc(networks, func(a, b Network) int {
+		return cmp.Or(cmp.Compare(a.Subnet, b.Subnet), cmp.Compare(a.PeerSignature, b.PeerSignature))
+	})
+	if networks == nil {
+		networks = []Network{}
+	}
+	return networks
+}
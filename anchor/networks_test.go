@@ -0,0 +1,61 @@
+package anchor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/veil-net/conflux/guardiantest"
+)
+
+func TestGuardianNetworks(t *testing.T) {
+	server, err := guardiantest.NewServer(guardiantest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := server.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	_, token, err := server.CreateRegistrationToken(server.Realm().ID, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := RegisterConflux(&ResgitrationRequest{RegistrationToken: token, Guardian: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.SetLocalNetworks(response.ConfluxID, []guardiantest.LocalNetwork{{Subnet: "192.168.1.0/24"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.SetRemoteNetworks(response.ConfluxID, []guardiantest.RemoteNetwork{{PeerSignature: "peer-1", Subnet: "10.0.0.0/8"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := GuardianLocalNetworks(url, server.AccessToken(), response.ConfluxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := local.GetLocalNetworks(); len(got) != 1 || got[0].GetSubnet() != "192.168.1.0/24" {
+		t.Errorf("local networks = %v, want 192.168.1.0/24", got)
+	}
+	remote, err := GuardianRemoteNetworks(url, server.AccessToken(), response.ConfluxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := remote.GetRemoteNetworks(); len(got) != 1 || got[0].GetSubnet() != "10.0.0.0/8" || got[0].GetPeerSignature() != "peer-1" {
+		t.Errorf("remote networks = %v, want 10.0.0.0/8 through peer-1", got)
+	}
+}
+
+func TestGuardianNetworksRejectsWrappedList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"remote_networks":[{"subnet":"10.0.0.0/8","peer_signature":"peer-1"}]}`))
+	}))
+	defer server.Close()
+	if _, err := GuardianRemoteNetworks(server.URL, "access-token", "conflux-1"); err == nil {
+		t.Error("expected an error for a list wrapped in an object")
+	}
+}
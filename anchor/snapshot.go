@@ -0,0 +1,103 @@
+package anchor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Snapshot is the conflux, realm, veil and tracer info of the running anchor, read together.
+type Snapshot struct {
+	Conflux *pb.GetInfoResponse
+	Realm   *pb.GetRealmInfoResponse
+	Veil    *pb.GetVeilInfoResponse
+	Tracer  *pb.TracerConfig
+}
+
+// GetSnapshot reads the conflux, realm, veil and tracer info concurrently.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the RPCs.
+//   - client: pb.AnchorClient. The client of the running anchor.
+//
+// Outputs:
+//   - *Snapshot. The snapshot.
+//   - err: error. Non-nil if any RPC fails; joins every failure.
+func GetSnapshot(ctx context.Context, client pb.AnchorClient) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	errs := make([]error, 4)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		var err error
+		if snapshot.Conflux, err = client.GetInfo(ctx, &emptypb.Empty{}); err != nil {
+			errs[0] = fmt.Errorf("failed to get conflux info: %w", err)
+		}
+	})
+	wg.Go(func() {
+		var err error
+		if snapshot.Realm, err = client.GetRealmInfo(ctx, &emptypb.Empty{}); err != nil {
+			errs[1] = fmt.Errorf("failed to get realm info: %w", err)
+		}
+	})
+	wg.Go(func() {
+		var err error
+		if snapshot.Veil, err = client.GetVeilInfo(ctx, &emptypb.Empty{}); err != nil {
+			errs[2] = fmt.Errorf("failed to get veil info: %w", err)
+		}
+	})
+	wg.Go(func() {
+		var err error
+		if snapshot.Tracer, err = client.GetTracerConfig(ctx, &emptypb.Empty{}); err != nil {
+			errs[3] = fmt.Errorf("failed to get tracer config: %w", err)
+		}
+	})
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// MarshalJSON encodes the snapshot as {"conflux", "realm", "veil", "tracer"} with proto field names.
+//
+// Inputs:
+//   - s: *Snapshot. The snapshot.
+//
+// Outputs:
+//   - []byte. The JSON document.
+//   - err: error. Non-nil if a message cannot be marshalled.
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	options := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+	sections := []struct {
+		name    string
+		message proto.Message
+	}{
+		{"conflux", s.Conflux},
+		{"realm", s.Realm},
+		{"veil", s.Veil},
+		{"tracer", s.Tracer},
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, section := range sections {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		fmt.Fprintf(&out, "%q:", section.name)
+		data, err := options.Marshal(section.message)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(data)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
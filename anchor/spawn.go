@@ -0,0 +1,49 @@
+package anchor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// AnchorAddressEnv replaces AnchorAddress as the address anchor clients dial, e.g. for a fake anchor on another port.
+const AnchorAddressEnv = "VEILNET_ANCHOR_ADDRESS"
+
+// AnchorCommandEnv replaces the embedded anchor binary with a command spawned as the anchor subprocess, e.g.
+// "veilnet-conflux dev anchor" for the fake anchor of the anchortest package, which needs neither the binary nor root.
+// It is only honoured by builds with the dev tag, so the environment of the service cannot replace the anchor.
+const AnchorCommandEnv = "VEILNET_ANCHOR_COMMAND"
+
+// Address returns the gRPC address of the anchor: VEILNET_ANCHOR_ADDRESS, or AnchorAddress where the embedded
+// binary listens.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - string. The host:port of the anchor.
+func Address() string {
+	if address := os.Getenv(AnchorAddressEnv); address != "" {
+		return address
+	}
+	return AnchorAddress
+}
+
+// startAnchorCommand starts the anchor command from AnchorCommandEnv as the anchor subprocess, forwarding its output
+// through logger like the embedded binary.
+func startAnchorCommand(command string, logger *zap.Logger, extraFiles []*os.File) (*exec.Cmd, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New(AnchorCommandEnv + " is empty")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = newLogWriter("stdout", logger)
+	cmd.Stderr = newLogWriter("stderr", logger)
+	cmd.ExtraFiles = extraFiles
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
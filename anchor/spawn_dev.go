@@ -0,0 +1,10 @@
+//go:build dev
+
+package anchor
+
+import "os"
+
+// anchorCommand returns the command from AnchorCommandEnv to spawn instead of the embedded binary.
+func anchorCommand() string {
+	return os.Getenv(AnchorCommandEnv)
+}
@@ -0,0 +1,8 @@
+//go:build !dev
+
+package anchor
+
+// anchorCommand returns no command outside dev builds, the embedded binary is always spawned.
+func anchorCommand() string {
+	return ""
+}
@@ -0,0 +1,118 @@
+package anchor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Proto converts the tracer config to the anchor's protobuf tracer config.
+//
+// Inputs:
+//   - t: *TracerConfig. The tracer config; may be nil.
+//
+// Outputs:
+//   - *pb.TracerConfig. The protobuf config, or nil if t is nil.
+func (t *TracerConfig) Proto() *pb.TracerConfig {
+	if t == nil {
+		return nil
+	}
+	return &pb.TracerConfig{
+		Enabled:  t.Enabled,
+		Endpoint: t.Endpoint,
+		UseTls:   t.UseTLS,
+		Insecure: t.Insecure,
+		Ca:       t.CAFile,
+		Cert:     t.CertFile,
+		Key:      t.KeyFile,
+	}
+}
+
+// Validate checks that the endpoint is a host:port and that the CA, certificate and key files exist and parse.
+//
+// Inputs:
+//   - t: *TracerConfig. The tracer config.
+//
+// Outputs:
+//   - err: error. Non-nil if the endpoint is missing or malformed or a file is invalid.
+func (t *TracerConfig) Validate() error {
+	if t.Endpoint == "" {
+		if t.Enabled {
+			return errors.New("an OTLP endpoint is required to enable the tracer")
+		}
+	} else {
+		host, port, err := net.SplitHostPort(t.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid OTLP endpoint %q, expected host:port: %w", t.Endpoint, err)
+		}
+		if host == "" {
+			return fmt.Errorf("invalid OTLP endpoint %q: missing host", t.Endpoint)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid OTLP endpoint %q: invalid port %q", t.Endpoint, port)
+		}
+	}
+	if t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" {
+		if _, err := t.Telemetry().TLSConfig(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartAnchorRequest builds the StartAnchor request for config.
+//
+// Inputs:
+//   - config: *ConfluxConfig. The conflux config.
+//
+// Outputs:
+//   - *pb.StartAnchorRequest. The request.
+func StartAnchorRequest(config *ConfluxConfig) *pb.StartAnchorRequest {
+	return &pb.StartAnchorRequest{
+		GuardianUrl: config.Guardian,
+		AnchorToken: config.Token,
+		Ip:          config.IP,
+		Rift:        config.Rift,
+		Portal:      config.Portal,
+		Conduit:     config.Conduit,
+		Tracer:      config.Tracer.Proto(),
+	}
+}
+
+// RestartAnchor stops the running anchor and starts it again with config, then re-applies the taints.
+// Used to apply settings such as the tracer without restarting the subprocess.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the RPCs.
+//   - client: pb.AnchorClient. The client of the running anchor.
+//   - config: *ConfluxConfig. The config to start the anchor with.
+//
+// Outputs:
+//   - err: error. Non-nil if stopping, starting or adding a taint fails.
+func RestartAnchor(ctx context.Context, client pb.AnchorClient, config *ConfluxConfig) error {
+	// Stop the anchor
+	if _, err := client.StopAnchor(ctx, &emptypb.Empty{}); err != nil {
+		Logger.Sugar().Errorf("failed to stop anchor: %v", err)
+		return err
+	}
+
+	// Start the anchor with the new config
+	if err := StartAnchor(ctx, client, config); err != nil {
+		Logger.Sugar().Errorf("failed to start anchor: %v", err)
+		return err
+	}
+
+	// Add taints
+	for _, taint := range config.Taints {
+		if _, err := client.AddTaint(ctx, &pb.AddTaintRequest{Taint: taint}); err != nil {
+			Logger.Sugar().Errorf("failed to add taint: %v", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package anchor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	pb "github.com/veil-net/conflux/proto"
+)
+
+// TUNFileDescriptor is the descriptor of the TUN in the anchor subprocess: the first file it inherits.
+const TUNFileDescriptor = 3
+
+// tunFDFileName is the TUN record in the config directory: the descriptor of the TUN the running anchor was started
+// with, absent when the anchor created its own.
+const tunFDFileName = "anchor.tun-fd"
+
+// OpenTUN wraps a TUN file descriptor inherited from the parent process, e.g. one a CNI plugin, systemd or the container
+// runtime created so that the conflux does not need the privilege to create it.
+//
+// Inputs:
+//   - fd: int. The inherited descriptor, at least 3.
+//
+// Outputs:
+//   - *os.File. The TUN.
+//   - err: error. Non-nil if fd is not an open descriptor or the platform cannot pass it to the anchor.
+func OpenTUN(fd int) (*os.File, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("passing a TUN file descriptor to the anchor is not supported on Windows")
+	}
+	if fd < 3 {
+		return nil, fmt.Errorf("invalid TUN file descriptor %d, descriptors 0-2 are stdin, stdout and stderr", fd)
+	}
+	tun := os.NewFile(uintptr(fd), "tun")
+	if _, err := tun.Stat(); err != nil {
+		return nil, fmt.Errorf("TUN file descriptor %d is not open: %w", fd, err)
+	}
+	return tun, nil
+}
+
+// NewAnchorWithTUN starts the anchor subprocess like NewAnchor, passing tun as its descriptor TUNFileDescriptor.
+//
+// Inputs:
+//   - tun: *os.File. The TUN, e.g. from OpenTUN.
+//
+// Outputs:
+//   - *exec.Cmd. The started anchor subprocess.
+//   - err: error. Non-nil if the binary cannot be extracted or started.
+func NewAnchorWithTUN(tun *os.File) (*exec.Cmd, error) {
+	return newAnchor(Logger, tun)
+}
+
+// StartAnchorWithFDRequest builds the StartAnchorWithFD request for config, with the TUN at fd in the anchor process.
+//
+// Inputs:
+//   - config: *ConfluxConfig. The conflux config.
+//   - fd: int32. The TUN descriptor in the anchor process.
+//
+// Outputs:
+//   - *pb.StartAnchorWithFDRequest. The request.
+func StartAnchorWithFDRequest(config *ConfluxConfig, fd int32) *pb.StartAnchorWithFDRequest {
+	return &pb.StartAnchorWithFDRequest{
+		GuardianUrl:    config.Guardian,
+		AnchorToken:    config.Token,
+		Ip:             config.IP,
+		Rift:           config.Rift,
+		Portal:         config.Portal,
+		Conduit:        config.Conduit,
+		Tracer:         config.Tracer.Proto(),
+		FileDescriptor: fd,
+	}
+}
+
+// StartAnchor starts the anchor with config: with the TUN at config.TUNFD when set, otherwise creating its own.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the RPC.
+//   - client: pb.AnchorClient. The client of the running anchor.
+//   - config: *ConfluxConfig. The config to start the anchor with.
+//
+// Outputs:
+//   - err: error. Non-nil if the RPC fails.
+func StartAnchor(ctx context.Context, client pb.AnchorClient, config *ConfluxConfig) error {
+	if config.TUNFD > 0 {
+		_, err := client.StartAnchorWithFD(ctx, StartAnchorWithFDRequest(config, config.TUNFD))
+		return err
+	}
+	_, err := client.StartAnchor(ctx, StartAnchorRequest(config))
+	return err
+}
+
+// SaveTUNFD records the TUN descriptor the anchor is started with, so commands restarting the running anchor from
+// another process (e.g. tracer set) start it with the same TUN instead of letting it create one.
+//
+// Inputs:
+//   - fd: int32. The TUN descriptor in the anchor process, 0 when the anchor creates its own TUN.
+//
+// Outputs:
+//   - err: error. Non-nil if the record cannot be written or removed.
+func SaveTUNFD(fd int32) error {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, tunFDFileName)
+	if fd <= 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(int(fd))+"\n"), 0644)
+}
+
+// loadTUNFD returns the TUN descriptor recorded in configDir by SaveTUNFD, 0 if none.
+func loadTUNFD(configDir string) int32 {
+	data, err := os.ReadFile(filepath.Join(configDir, tunFDFileName))
+	if err != nil {
+		return 0
+	}
+	fd, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil || fd < 0 {
+		return 0
+	}
+	return int32(fd)
+}
@@ -0,0 +1,48 @@
+package anchor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRestartKeepsInheritedTUN(t *testing.T) {
+	t.Setenv(CredentialStoreEnv, CredentialStorePlaintext)
+	server, client := startFakeAnchor(t)
+	if err := SaveConfig(&ConfluxConfig{ConfluxID: "conflux-1", Token: "token-1", Guardian: "https://guardian.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process restarting the anchor starts it with the recorded descriptor
+	if err := SaveTUNFD(7); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TUNFD != 7 {
+		t.Fatalf("TUNFD = %d, want 7", config.TUNFD)
+	}
+	if err := RestartAnchor(context.Background(), client, config); err != nil {
+		t.Fatal(err)
+	}
+	calls := server.Calls()
+	if last := calls[len(calls)-1]; last.Method != "StartAnchorWithFD" || server.Started().GetFileDescriptor() != 7 {
+		t.Errorf("restart called %s with descriptor %d, want StartAnchorWithFD with 7", last.Method, server.Started().GetFileDescriptor())
+	}
+
+	// Without a record the anchor creates its own TUN
+	if err := SaveTUNFD(0); err != nil {
+		t.Fatal(err)
+	}
+	if config, err = LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestartAnchor(context.Background(), client, config); err != nil {
+		t.Fatal(err)
+	}
+	calls = server.Calls()
+	if last := calls[len(calls)-1]; last.Method != "StartAnchor" {
+		t.Errorf("restart called %s, want StartAnchor", last.Method)
+	}
+}
@@ -1,10 +1,11 @@
-// Package anchor provides config, registration, and anchor subprocess/client helpers for conflux.
+// Package anchor provides config, registration, and anchor subprocess/client helpers for conflux, and Conflux to embed a conflux in a Go program.
 package anchor
 
 import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,11 +13,29 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/veil-net/conflux/guardian"
+	"github.com/veil-net/conflux/logger"
+	"github.com/veil-net/conflux/metrics"
 	pb "github.com/veil-net/conflux/proto"
+	"github.com/veil-net/conflux/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// Logger re-exports the global logger for the anchor package.
+var Logger = logger.Logger
+
+// ErrConfluxNotFound is returned by UnregisterConflux and LogoutConflux when the Guardian does not know the conflux.
+var ErrConfluxNotFound = errors.New("conflux not found")
+
+// AnchorAddress is the local gRPC address the anchor subprocess listens on.
+const AnchorAddress = "127.0.0.1:1993"
+
 // TracerConfig holds OTLP/tracing settings (enabled, endpoint, TLS, certs).
 type TracerConfig struct {
 	Enabled  bool   `json:"enabled" validate:"required"`
@@ -28,6 +47,28 @@ type TracerConfig struct {
 	KeyFile  string `json:"key_file" validate:"required"`
 }
 
+// Telemetry converts the tracer config to the wrapper's telemetry config.
+//
+// Inputs:
+//   - t: *TracerConfig. The tracer config; may be nil.
+//
+// Outputs:
+//   - *telemetry.Config. The telemetry config, or nil if t is nil.
+func (t *TracerConfig) Telemetry() *telemetry.Config {
+	if t == nil {
+		return nil
+	}
+	return &telemetry.Config{
+		Enabled:  t.Enabled,
+		Endpoint: t.Endpoint,
+		UseTLS:   t.UseTLS,
+		Insecure: t.Insecure,
+		CAFile:   t.CAFile,
+		CertFile: t.CertFile,
+		KeyFile:  t.KeyFile,
+	}
+}
+
 type IDPConfig struct {
 	JWT string `json:"jwt" validate:"required"`
 	JWKS_url string `json:"jwks_url" validate:"required"`
@@ -35,17 +76,45 @@ type IDPConfig struct {
 	Issuer string `json:"issuer" validate:"required"`
 }
 
-// ConfluxConfig holds conflux runtime config (ID, token, guardian, rift/portal, IP, taints, tracer).
+// ConfluxConfig holds conflux runtime config (ID, token, guardian, rift/portal, IP, tag, taints, tracer, logging, HTTP monitor).
 type ConfluxConfig struct {
-	ConfluxID string        `json:"conflux_id" validate:"required"`
-	Token     string        `json:"conflux_token" validate:"required"`
-	Guardian  string        `json:"guardian" validate:"required"`
-	Rift      bool          `json:"rift" validate:"required"`
-	Portal    bool          `json:"portal" validate:"required"`
-	Conduit   bool          `json:"conduit" validate:"required"`
-	IP        string        `json:"ip" validate:"required"`
-	Taints    []string      `json:"taints"`
-	Tracer    *TracerConfig `json:"tracer"`
+	ConfluxID string          `json:"conflux_id" validate:"required"`
+	Token     string          `json:"conflux_token" validate:"required"`
+	Guardian  string          `json:"guardian" validate:"required"`
+	Rift      bool            `json:"rift" validate:"required"`
+	Portal    bool            `json:"portal" validate:"required"`
+	Conduit   bool            `json:"conduit" validate:"required"`
+	IP        string          `json:"ip" validate:"required"`
+	Taints    []string        `json:"taints"`
+	Tracer    *TracerConfig   `json:"tracer"`
+	Logging   *logger.Options `json:"logging"`
+	HTTPAddr  string          `json:"http_addr"`
+	Tag       string          `json:"tag,omitempty"`
+	// Registered is set when the register command writes the config of a new registration for the service, which
+	// counts it in conflux_registrations_total once and clears it; the command runs in another process.
+	Registered bool `json:"registered,omitempty"`
+	// TUNFD is the descriptor of a TUN passed to the anchor subprocess, set at runtime and never saved in the file;
+	// the running service records it with SaveTUNFD so LoadConfig restores it in other processes.
+	TUNFD int32 `json:"-"`
+	// CredentialStore names the secure store holding the token (dpapi, keychain, sealed-file); empty when the token
+	// is stored in plaintext in the file.
+	CredentialStore string `json:"credential_store,omitempty"`
+}
+
+// SecretConfigKeys are the config keys whose values are masked by config show and by support bundles from the
+// secrets level on: credentials and the conflux ID. Keys ending in "_token" are masked as well.
+var SecretConfigKeys = []string{"conflux_id", "token", "jwt", "key", "password", "secret", "authorization"}
+
+// IsSecretConfigKey reports whether the value of a config key is masked, see SecretConfigKeys.
+//
+// Inputs:
+//   - key: string. The config or JSON key, in any case.
+//
+// Outputs:
+//   - bool. True for the keys of SecretConfigKeys and keys ending in "_token".
+func IsSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	return slices.Contains(SecretConfigKeys, key) || strings.HasSuffix(key, "_token")
 }
 
 // ResgitrationRequest is the request payload for conflux registration (token, guardian, tag, JWT/JWKS, etc.).
@@ -65,7 +134,47 @@ type RegistrationResponse struct {
 	Token     string `json:"token" validate:"required"`
 }
 
-// GetConfigDir returns the OS-specific config directory for conflux.
+// dialOptions returns the gRPC dial options used for the anchor client (plaintext loopback, metrics interceptor,
+// OpenTelemetry stats handler).
+//
+// Inputs: none.
+//
+// Outputs:
+//   - []grpc.DialOption. The options to pass to grpc.NewClient.
+func dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor()),
+		grpc.WithStatsHandler(telemetry.GRPCClientHandler()),
+	}
+}
+
+// DialAnchor creates a gRPC connection to the local anchor server, for long-running callers that must close it.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *grpc.ClientConn. The connection to Address(); close it when done.
+//   - err: error. Non-nil if the connection cannot be created.
+func DialAnchor() (*grpc.ClientConn, error) {
+	return grpc.NewClient(Address(), dialOptions()...)
+}
+
+// configDirOverride replaces the OS-specific config directory when set by SetConfigDir.
+var configDirOverride string
+
+// SetConfigDir replaces the OS-specific config directory, e.g. with a volume mounted in a container so the conflux
+// identity outlives the container.
+//
+// Inputs:
+//   - dir: string. The config directory; empty restores the OS-specific one.
+//
+// Outputs: none.
+func SetConfigDir(dir string) {
+	configDirOverride = dir
+}
+
+// GetConfigDir returns the config directory set by SetConfigDir, or the OS-specific config directory for conflux.
 //
 // Inputs: none.
 //
@@ -73,6 +182,9 @@ type RegistrationResponse struct {
 //   - configDir: string. The config directory path.
 //   - err: error. Non-nil if the directory cannot be determined.
 func GetConfigDir() (string, error) {
+	if configDirOverride != "" {
+		return filepath.Abs(configDirOverride)
+	}
 	var configDir string
 
 	switch runtime.GOOS {
@@ -91,13 +203,14 @@ func GetConfigDir() (string, error) {
 	return configDir, nil
 }
 
-// LoadConfig loads ConfluxConfig from the config file.
+// LoadConfig loads ConfluxConfig from the config file, reading the token from the credential store if it was moved there
+// and the TUN descriptor the running anchor was started with from the TUN record.
 //
 // Inputs: none.
 //
 // Outputs:
 //   - config: *ConfluxConfig. The loaded config.
-//   - err: error. Non-nil if the file is missing or invalid.
+//   - err: error. Non-nil if the file is missing or invalid, or the token cannot be read from the credential store.
 func LoadConfig() (*ConfluxConfig, error) {
 	configDir, err := GetConfigDir()
 	if err != nil {
@@ -113,10 +226,19 @@ func LoadConfig() (*ConfluxConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.CredentialStore != "" && config.Token == "" {
+		if config.Token, err = loadToken(configDir, config); err != nil {
+			Logger.Sugar().Errorf("failed to load the conflux token: %v", err)
+			return nil, err
+		}
+	}
+	config.TUNFD = loadTUNFD(configDir)
 	return config, nil
 }
 
-// SaveConfig writes ConfluxConfig to the config file.
+// SaveConfig atomically replaces the config file with ConfluxConfig, moving the token into the credential store of the OS; the token
+// stays in the file, readable by the owner only, when no secure store is usable or VEILNET_CREDENTIAL_STORE=plaintext.
+// The store used is recorded in config.CredentialStore.
 //
 // Inputs:
 //   - config: *ConfluxConfig. The conflux config to write.
@@ -129,21 +251,75 @@ func SaveConfig(config *ConfluxConfig) error {
 		return err
 	}
 	configFilePath := filepath.Join(configDir, "conflux.json")
-	configFile, err := json.Marshal(config)
+	saved := storeToken(configDir, config)
+	configFile, err := json.Marshal(saved)
 	if err != nil {
 		return err
 	}
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return err
 	}
-	err = os.WriteFile(configFilePath, configFile, 0644)
+	err = writeConfigFile(configFilePath, configFile)
 	if err != nil {
 		return err
 	}
+	config.CredentialStore = saved.CredentialStore
 	return nil
 }
 
-// DeleteConfig removes the config file.
+// ClearRegistered clears the registered flag of the config file once the service has counted the registration. The
+// rest of the file, including where the token is kept, is left as it is.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - err: error. Non-nil if the file cannot be read or written.
+func ClearRegistered() error {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+	configFilePath := filepath.Join(configDir, "conflux.json")
+	data, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return err
+	}
+	config := &ConfluxConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return err
+	}
+	if !config.Registered {
+		return nil
+	}
+	config.Registered = false
+	if data, err = json.Marshal(config); err != nil {
+		return err
+	}
+	return writeConfigFile(configFilePath, data)
+}
+
+// writeConfigFile replaces the config file atomically with a file readable by the owner only, so a reader never sees
+// a partial write.
+func writeConfigFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// DeleteConfig removes the config file and the token in the credential store.
 //
 // Inputs: none.
 //
@@ -155,6 +331,7 @@ func DeleteConfig() error {
 		return err
 	}
 	configFilePath := filepath.Join(configDir, "conflux.json")
+	deleteToken(configDir)
 	return os.Remove(configFilePath)
 }
 
@@ -167,6 +344,15 @@ func DeleteConfig() error {
 //   - *RegistrationResponse. The registration response (ConfluxID, token).
 //   - err: error. Non-nil if the guardian request fails.
 func RegisterConflux(config *ResgitrationRequest) (*RegistrationResponse, error) {
+	_, end := telemetry.StartPhase(context.Background(), "register", attribute.String("guardian", config.Guardian))
+	response, err := registerConflux(config)
+	end(err)
+	metrics.RecordRegistration(err)
+	return response, err
+}
+
+// registerConflux performs the registration request; RegisterConflux wraps it to record the outcome.
+func registerConflux(config *ResgitrationRequest) (*RegistrationResponse, error) {
 	// Marshal the request body
 	body, err := json.Marshal(config)
 	if err != nil {
@@ -182,9 +368,8 @@ func RegisterConflux(config *ResgitrationRequest) (*RegistrationResponse, error)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", config.RegistrationToken))
 	req.Header.Set("Content-Type", "application/json")
 
-	// Make the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Make the request; the Guardian may create a conflux per request, so the registration is never retried
+	resp, err := guardian.Default().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -222,7 +407,7 @@ func RegisterConflux(config *ResgitrationRequest) (*RegistrationResponse, error)
 //   - config: *ConfluxConfig. Current conflux config (Guardian, ConfluxID).
 //
 // Outputs:
-//   - err: error. Non-nil if the guardian request fails.
+//   - err: error. Non-nil if the guardian request fails; wraps ErrConfluxNotFound if the Guardian does not know the conflux.
 func UnregisterConflux(registrationToken string, config *ConfluxConfig) error {
 	// Create the request
 	url := fmt.Sprintf("%s/conflux/unregister?conflux_id=%s", config.Guardian, config.ConfluxID)
@@ -235,8 +420,7 @@ func UnregisterConflux(registrationToken string, config *ConfluxConfig) error {
 	req.Header.Set("Content-Type", "application/json")
 
 	// Make the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := guardian.Default().Do(req)
 	if err != nil {
 		return err
 	}
@@ -248,11 +432,53 @@ func UnregisterConflux(registrationToken string, config *ConfluxConfig) error {
 		if err != nil {
 			return err
 		}
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s: %s", ErrConfluxNotFound, resp.Status, string(body))
+		}
 		return fmt.Errorf("failed to unregister conflux: %s: %s", resp.Status, string(body))
 	}
 	return nil
 }
 
+// LogoutConflux ends the session of the conflux with its conflux token; the Guardian also deletes a conflux that is
+// not a portal.
+//
+// Inputs:
+//   - guardianURL: string. The Guardian URL.
+//   - token: string. The conflux token.
+//
+// Outputs:
+//   - err: error. Non-nil if the guardian request fails; wraps ErrConfluxNotFound if the Guardian rejects the token,
+//     which it does once the conflux and its tokens are gone.
+func LogoutConflux(guardianURL string, token string) error {
+	// Create the request
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/conflux/session/logout", guardianURL), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-conflux-token", token)
+
+	// Make the request
+	resp, err := guardian.Default().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// if the response is not 200, return an error
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s: %s", ErrConfluxNotFound, resp.Status, string(body))
+		}
+		return fmt.Errorf("failed to log out conflux: %s: %s", resp.Status, string(body))
+	}
+	return nil
+}
+
 // StartConflux registers the conflux, starts the anchor subprocess, creates a gRPC client, and starts the anchor.
 //
 // Inputs:
@@ -263,10 +489,12 @@ func UnregisterConflux(registrationToken string, config *ConfluxConfig) error {
 //   - subprocess: *exec.Cmd. The started anchor subprocess.
 //   - anchor: pb.AnchorClient. The gRPC client.
 //   - err: error. Non-nil if registration or anchor start fails.
+//
+// Deprecated: use NewConflux, which also takes the Guardian, modes and taints and manages the subprocess.
 func StartConflux(token string, ip string, tag string, idp *IDPConfig, tracer *TracerConfig) (subprocess *exec.Cmd, anchor pb.AnchorClient, err error) {
 
 
-	guardian := "https://guardian.veilnet.app"
+	guardian := DefaultGuardian
 
 	// Parse the command
 	registrationRequest := &ResgitrationRequest{
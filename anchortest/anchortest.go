@@ -0,0 +1,449 @@
+// Package anchortest provides a fake anchor: an in-process pb.AnchorServer with scriptable state, failure injection
+// and call recording, so the CLI, service and lifecycle code can run without the embedded anchor binary or root.
+//
+// Tests either serve it in process and point clients at it with VEILNET_ANCHOR_ADDRESS, or spawn it in place of the
+// real binary by setting VEILNET_ANCHOR_COMMAND to "veilnet-conflux dev anchor"; both the variable and the dev command
+// need a build with the dev tag (go build -tags dev).
+package anchortest
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Call is an RPC received by the fake anchor.
+type Call struct {
+	// Method is the RPC name, e.g. StartAnchor.
+	Method string
+	// Request is a copy of the request message.
+	Request proto.Message
+	// Time is when the call was received.
+	Time time.Time
+}
+
+// Server is a fake anchor. Its Get RPCs fail with FailedPrecondition until the anchor is started, like the real one.
+type Server struct {
+	pb.UnimplementedAnchorServer
+
+	mu       sync.Mutex
+	info     *pb.GetInfoResponse
+	realm    *pb.GetRealmInfoResponse
+	veil     *pb.GetVeilInfoResponse
+	tracer   *pb.TracerConfig
+	taints   []string
+	local    *pb.LocalNetworks
+	remote   *pb.RemoteNetworks
+	started  *pb.StartAnchorWithFDRequest
+	failures map[string]error
+	calls    []Call
+	grpc     *grpc.Server
+}
+
+// NewServer creates a fake anchor whose state, once started, passes the anchor health checks.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *Server. The fake anchor, not yet serving.
+func NewServer() *Server {
+	return &Server{
+		info:     &pb.GetInfoResponse{Id: "00000000-0000-0000-0000-000000000000", Tag: "anchortest", Uid: "anchortest"},
+		realm:    &pb.GetRealmInfoResponse{Realm: "anchortest", RealmId: "00000000-0000-0000-0000-000000000000", Subnet: "10.128.0.0/16"},
+		veil:     &pb.GetVeilInfoResponse{VeilHost: "127.0.0.1", VeilPort: 443, Region: "local"},
+		tracer:   &pb.TracerConfig{},
+		local:    &pb.LocalNetworks{},
+		remote:   &pb.RemoteNetworks{},
+		failures: map[string]error{},
+	}
+}
+
+// Start serves the fake anchor on address in the background.
+//
+// Inputs:
+//   - address: string. The host:port to listen on, port 0 for any free port.
+//
+// Outputs:
+//   - string. The address the fake anchor listens on.
+//   - err: error. Non-nil if the address cannot be listened on.
+func (s *Server) Start(address string) (string, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	server := grpc.NewServer()
+	pb.RegisterAnchorServer(server, s)
+	s.mu.Lock()
+	s.grpc = server
+	s.mu.Unlock()
+	go server.Serve(listener)
+	return listener.Addr().String(), nil
+}
+
+// Stop stops serving and closes open connections.
+//
+// Inputs: none.
+//
+// Outputs: none.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	server := s.grpc
+	s.grpc = nil
+	s.mu.Unlock()
+	if server != nil {
+		server.Stop()
+	}
+}
+
+// Info returns a copy of the conflux info GetInfo returns once the anchor is started.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *pb.GetInfoResponse. The conflux info.
+func (s *Server) Info() *pb.GetInfoResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return proto.Clone(s.info).(*pb.GetInfoResponse)
+}
+
+// Realm returns a copy of the realm info GetRealmInfo returns once the anchor is started.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *pb.GetRealmInfoResponse. The realm info.
+func (s *Server) Realm() *pb.GetRealmInfoResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return proto.Clone(s.realm).(*pb.GetRealmInfoResponse)
+}
+
+// Veil returns a copy of the veil info GetVeilInfo returns once the anchor is started.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *pb.GetVeilInfoResponse. The veil info.
+func (s *Server) Veil() *pb.GetVeilInfoResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return proto.Clone(s.veil).(*pb.GetVeilInfoResponse)
+}
+
+// SetInfo replaces the conflux info returned by GetInfo; StartAnchor still applies its IP and flags on top.
+//
+// Inputs:
+//   - info: *pb.GetInfoResponse. The conflux info.
+//
+// Outputs: none.
+func (s *Server) SetInfo(info *pb.GetInfoResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info = proto.Clone(info).(*pb.GetInfoResponse)
+}
+
+// SetRealm replaces the realm info returned by GetRealmInfo; an empty realm ID fails the realm health check.
+//
+// Inputs:
+//   - realm: *pb.GetRealmInfoResponse. The realm info.
+//
+// Outputs: none.
+func (s *Server) SetRealm(realm *pb.GetRealmInfoResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.realm = proto.Clone(realm).(*pb.GetRealmInfoResponse)
+}
+
+// SetVeil replaces the veil info returned by GetVeilInfo; an empty veil host fails the veil health check.
+//
+// Inputs:
+//   - veil: *pb.GetVeilInfoResponse. The veil info.
+//
+// Outputs: none.
+func (s *Server) SetVeil(veil *pb.GetVeilInfoResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.veil = proto.Clone(veil).(*pb.GetVeilInfoResponse)
+}
+
+// SetTracer replaces the tracer config returned by GetTracerConfig until the next StartAnchor.
+//
+// Inputs:
+//   - tracer: *pb.TracerConfig. The tracer config.
+//
+// Outputs: none.
+func (s *Server) SetTracer(tracer *pb.TracerConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracer = proto.Clone(tracer).(*pb.TracerConfig)
+}
+
+// SetTaints replaces the taints of the fake anchor.
+//
+// Inputs:
+//   - taints: []string. The taints.
+//
+// Outputs: none.
+func (s *Server) SetTaints(taints []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.taints = slices.Clone(taints)
+}
+
+// SetLocalNetworks replaces the subnets returned by GetLocalNetworks.
+//
+// Inputs:
+//   - networks: *pb.LocalNetworks. The local networks.
+//
+// Outputs: none.
+func (s *Server) SetLocalNetworks(networks *pb.LocalNetworks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.local = proto.Clone(networks).(*pb.LocalNetworks)
+}
+
+// SetRemoteNetworks replaces the peer subnets returned by GetRemoteNetworks.
+//
+// Inputs:
+//   - networks: *pb.RemoteNetworks. The remote networks.
+//
+// Outputs: none.
+func (s *Server) SetRemoteNetworks(networks *pb.RemoteNetworks) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remote = proto.Clone(networks).(*pb.RemoteNetworks)
+}
+
+// Taints returns the current taints, e.g. to check what AddTaint and RemoveTaint left.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - []string. The taints.
+func (s *Server) Taints() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.taints)
+}
+
+// Started returns the request the anchor was last started with; StartAnchor requests are converted with a zero
+// file descriptor.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *pb.StartAnchorWithFDRequest. The start request, nil if the anchor is not started.
+func (s *Server) Started() *pb.StartAnchorWithFDRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		return nil
+	}
+	return proto.Clone(s.started).(*pb.StartAnchorWithFDRequest)
+}
+
+// Fail makes every later call of method return err, before the call changes any state.
+//
+// Inputs:
+//   - method: string. The RPC name, e.g. StartAnchor or GetInfo.
+//   - err: error. The error to return, e.g. status.Error(codes.Unavailable, "..."); nil stops failing the method.
+//
+// Outputs: none.
+func (s *Server) Fail(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.failures, method)
+		return
+	}
+	s.failures[method] = err
+}
+
+// Calls returns the RPCs received so far, oldest first, including the failed ones.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - []Call. The calls.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.calls)
+}
+
+// record records a call and returns the injected failure of its method.
+func (s *Server) record(method string, request proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Method: method, Request: proto.Clone(request), Time: time.Now()})
+	return s.failures[method]
+}
+
+// StartAnchor starts the fake anchor, applying the IP, flags and tracer of the request.
+func (s *Server) StartAnchor(ctx context.Context, req *pb.StartAnchorRequest) (*emptypb.Empty, error) {
+	if err := s.record("StartAnchor", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start(&pb.StartAnchorWithFDRequest{
+		GuardianUrl: req.GetGuardianUrl(),
+		AnchorToken: req.GetAnchorToken(),
+		Ip:          req.GetIp(),
+		Rift:        req.GetRift(),
+		Portal:      req.GetPortal(),
+		Conduit:     req.GetConduit(),
+		Tracer:      req.GetTracer(),
+	})
+	return &emptypb.Empty{}, nil
+}
+
+// StartAnchorWithFD starts the fake anchor like StartAnchor; the descriptor is only recorded.
+func (s *Server) StartAnchorWithFD(ctx context.Context, req *pb.StartAnchorWithFDRequest) (*emptypb.Empty, error) {
+	if err := s.record("StartAnchorWithFD", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.start(req)
+	return &emptypb.Empty{}, nil
+}
+
+// start applies a start request; the lock must be held.
+func (s *Server) start(req *pb.StartAnchorWithFDRequest) {
+	s.started = proto.Clone(req).(*pb.StartAnchorWithFDRequest)
+	s.info.Cidr = req.GetIp()
+	s.info.Rift = req.GetRift()
+	s.info.Portal = req.GetPortal()
+	s.info.Conduit = req.GetConduit()
+	s.tracer = &pb.TracerConfig{}
+	if req.GetTracer() != nil {
+		s.tracer = proto.Clone(req.GetTracer()).(*pb.TracerConfig)
+	}
+}
+
+// StopAnchor stops the fake anchor; the process keeps serving, like the real one until it is killed.
+func (s *Server) StopAnchor(ctx context.Context, req *emptypb.Empty) (*emptypb.Empty, error) {
+	if err := s.record("StopAnchor", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = nil
+	return &emptypb.Empty{}, nil
+}
+
+// AddTaint adds a taint unless it is already set.
+func (s *Server) AddTaint(ctx context.Context, req *pb.AddTaintRequest) (*emptypb.Empty, error) {
+	if err := s.record("AddTaint", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.taints, req.GetTaint()) {
+		s.taints = append(s.taints, req.GetTaint())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// RemoveTaint removes a taint.
+func (s *Server) RemoveTaint(ctx context.Context, req *pb.RemoveTaintRequest) (*emptypb.Empty, error) {
+	if err := s.record("RemoveTaint", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.taints = slices.DeleteFunc(s.taints, func(taint string) bool { return taint == req.GetTaint() })
+	return &emptypb.Empty{}, nil
+}
+
+// GetInfo returns the conflux info.
+func (s *Server) GetInfo(ctx context.Context, req *emptypb.Empty) (*pb.GetInfoResponse, error) {
+	if err := s.record("GetInfo", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		return nil, errNotStarted
+	}
+	return proto.Clone(s.info).(*pb.GetInfoResponse), nil
+}
+
+// GetRealmInfo returns the realm info.
+func (s *Server) GetRealmInfo(ctx context.Context, req *emptypb.Empty) (*pb.GetRealmInfoResponse, error) {
+	if err := s.record("GetRealmInfo", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		return nil, errNotStarted
+	}
+	return proto.Clone(s.realm).(*pb.GetRealmInfoResponse), nil
+}
+
+// GetVeilInfo returns the veil info.
+func (s *Server) GetVeilInfo(ctx context.Context, req *emptypb.Empty) (*pb.GetVeilInfoResponse, error) {
+	if err := s.record("GetVeilInfo", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		return nil, errNotStarted
+	}
+	return proto.Clone(s.veil).(*pb.GetVeilInfoResponse), nil
+}
+
+// GetTracerConfig returns the tracer config.
+func (s *Server) GetTracerConfig(ctx context.Context, req *emptypb.Empty) (*pb.TracerConfig, error) {
+	if err := s.record("GetTracerConfig", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		return nil, errNotStarted
+	}
+	return proto.Clone(s.tracer).(*pb.TracerConfig), nil
+}
+
+// GetLocalNetworks returns the subnets the fake anchor advertises.
+func (s *Server) GetLocalNetworks(ctx context.Context, req *emptypb.Empty) (*pb.LocalNetworks, error) {
+	if err := s.record("GetLocalNetworks", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		return nil, errNotStarted
+	}
+	return proto.Clone(s.local).(*pb.LocalNetworks), nil
+}
+
+// GetRemoteNetworks returns the subnets the fake anchor reaches through its peers.
+func (s *Server) GetRemoteNetworks(ctx context.Context, req *emptypb.Empty) (*pb.RemoteNetworks, error) {
+	if err := s.record("GetRemoteNetworks", req); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started == nil {
+		return nil, errNotStarted
+	}
+	return proto.Clone(s.remote).(*pb.RemoteNetworks), nil
+}
+
+// errNotStarted is returned by the Get RPCs before StartAnchor.
+var errNotStarted = status.Error(codes.FailedPrecondition, "anchor is not started")
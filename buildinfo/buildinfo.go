@@ -0,0 +1,51 @@
+// Package buildinfo exposes the version and build metadata of the conflux binary.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the release version; main sets it from its own version string at startup.
+var Version = "dev"
+
+// Info is the version and build metadata of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	Modified  bool   `json:"modified"`
+}
+
+// Get returns the version and build metadata, reading the VCS settings embedded by the Go toolchain.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - Info. The build metadata; VCS fields are empty when the binary was built without VCS information.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			info.BuildTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
@@ -0,0 +1,170 @@
+// Package bundle writes support bundles: a tarball of the redacted config, service state, anchor logs, info and
+// doctor results of a conflux.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/buildinfo"
+	"github.com/veil-net/conflux/doctor"
+	"github.com/veil-net/conflux/logger"
+	"github.com/veil-net/conflux/service"
+)
+
+// Logger re-exports the global logger for the bundle package.
+var Logger = logger.Logger
+
+// Options holds the settings of a bundle.
+type Options struct {
+	// Path is the tarball to write.
+	Path string
+	// RedactLevel selects what is masked: none, secrets or strict.
+	RedactLevel RedactLevel
+	// Doctor holds the settings of the doctor checks included in the bundle.
+	Doctor doctor.Options
+	// Timeout bounds the anchor RPCs.
+	Timeout time.Duration
+}
+
+// file is a single file of the bundle.
+type file struct {
+	name string
+	data []byte
+}
+
+// Write collects the bundle and writes it as a gzipped tarball. Items that cannot be collected are listed in
+// errors.txt instead of failing the bundle.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the anchor RPCs and doctor checks.
+//   - options: Options. The output path, redaction level and check settings.
+//
+// Outputs:
+//   - err: error. Non-nil if the redaction level is invalid or the tarball cannot be written.
+func Write(ctx context.Context, options Options) error {
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+	redactor, err := newRedactor(options.RedactLevel)
+	if err != nil {
+		return err
+	}
+
+	var files []file
+	var failures []string
+	add := func(name string, data []byte, err error) {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		files = append(files, file{name: name, data: data})
+	}
+	addJSON := func(name string, value any, err error) {
+		if err != nil {
+			add(name, nil, err)
+			return
+		}
+		data, err := json.MarshalIndent(value, "", "  ")
+		add(name, append(data, '\n'), err)
+	}
+
+	// Version and build info
+	addJSON("version.json", buildinfo.Get(), nil)
+
+	// Config
+	config, configErr := anchor.LoadConfig()
+	if configErr == nil {
+		redactor.learn(config)
+	}
+	configDir, _ := anchor.GetConfigDir()
+	data, err := os.ReadFile(filepath.Join(configDir, "conflux.json"))
+	add("conflux.json", data, err)
+
+	// Service unit and state
+	conflux := service.NewService()
+	unit, err := conflux.Unit()
+	add("service-unit.txt", []byte(unit), err)
+	state, err := conflux.State()
+	addJSON("status.json", state, err)
+
+	// Anchor logs
+	lines, err := anchor.LoadLogs()
+	if err == nil {
+		var logs strings.Builder
+		for _, line := range lines {
+			logs.WriteString(line.String())
+			logs.WriteByte('\n')
+		}
+		data = []byte(logs.String())
+	}
+	add("anchor.log", data, err)
+
+	// Info RPCs
+	client, err := anchor.NewAnchorClient()
+	if err == nil {
+		rpcCtx, cancel := context.WithTimeout(ctx, options.Timeout)
+		var snapshot *anchor.Snapshot
+		snapshot, err = anchor.GetSnapshot(rpcCtx, client)
+		cancel()
+		addJSON("info.json", snapshot, err)
+	} else {
+		add("info.json", nil, err)
+	}
+
+	// Doctor checks
+	addJSON("doctor.json", doctor.Run(ctx, options.Doctor), nil)
+
+	if len(failures) > 0 {
+		add("errors.txt", []byte(strings.Join(failures, "\n")+"\n"), nil)
+	}
+
+	// Redact every file
+	for i := range files {
+		files[i].data = redactor.redact(files[i].name, files[i].data)
+	}
+	return writeTarball(options.Path, files)
+}
+
+// writeTarball writes files into a gzipped tarball under a directory named after the tarball.
+func writeTarball(path string, files []file) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	dir := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".tar")
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	now := time.Now().Truncate(time.Second)
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    dir + "/" + f.name,
+			Mode:    0600,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
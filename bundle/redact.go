@@ -0,0 +1,152 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/veil-net/conflux/anchor"
+)
+
+// RedactLevel selects what a bundle masks.
+type RedactLevel string
+
+// Redaction levels, from least to most masking.
+const (
+	// RedactNone keeps every value; only for bundles that stay on the host.
+	RedactNone RedactLevel = "none"
+	// RedactSecrets masks tokens, JWTs, keys, passwords and the conflux ID, like config show.
+	RedactSecrets RedactLevel = "secrets"
+	// RedactStrict also masks identifiers and addresses (IDs, IPs, hosts, realm) so bundles are safe for tickets.
+	RedactStrict RedactLevel = "strict"
+)
+
+// redacted replaces masked values.
+const redacted = "***REDACTED***"
+
+// identityKeys are the JSON keys also masked at RedactStrict.
+var identityKeys = []string{
+	"id", "uid", "tag", "ip", "cidr", "subnet", "realm", "realm_id",
+	"veil_host", "guardian", "guardian_url", "endpoint", "jwks_url", "http_addr",
+}
+
+// Patterns masked in free text from RedactSecrets on.
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	secretPattern = regexp.MustCompile(`(?i)((?:token|jwt|secret|password)["']?\s*[:=]\s*["']?)[^\s"',}]+`)
+)
+
+// Patterns also masked in free text at RedactStrict.
+var (
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	uuidPattern = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+)
+
+// redactor masks the content of bundle files according to a level.
+type redactor struct {
+	level RedactLevel
+	// literals are known sensitive values (e.g. the conflux token) masked wherever they appear.
+	literals []string
+}
+
+// newRedactor returns a redactor for level; empty selects RedactSecrets.
+func newRedactor(level RedactLevel) (*redactor, error) {
+	switch level {
+	case "":
+		level = RedactSecrets
+	case RedactNone, RedactSecrets, RedactStrict:
+	default:
+		return nil, fmt.Errorf("invalid redact level %q: must be none, secrets or strict", level)
+	}
+	return &redactor{level: level}, nil
+}
+
+// learn records the sensitive values of config so they are masked in logs and other free text.
+func (r *redactor) learn(config *anchor.ConfluxConfig) {
+	r.literals = append(r.literals, config.Token, config.ConfluxID)
+	if r.level == RedactStrict {
+		r.literals = append(r.literals, config.IP)
+	}
+	r.literals = slices.DeleteFunc(r.literals, func(value string) bool { return len(value) < 4 })
+}
+
+// redact masks a file; JSON files have their sensitive keys masked, then every file has its free text masked.
+func (r *redactor) redact(name string, data []byte) []byte {
+	if r.level == RedactNone {
+		return data
+	}
+	if strings.HasSuffix(name, ".json") {
+		if masked, err := r.redactJSON(data); err == nil {
+			data = masked
+		}
+	}
+	return r.redactText(data)
+}
+
+// redactJSON masks the values of sensitive keys anywhere in a JSON document.
+func (r *redactor) redactJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	masked, err := json.MarshalIndent(r.redactValue(document), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(masked, '\n'), nil
+}
+
+// redactValue masks the sensitive keys of value recursively.
+func (r *redactor) redactValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			if text, ok := child.(string); ok && text != "" && r.sensitive(key) {
+				value[key] = redacted
+				continue
+			}
+			value[key] = r.redactValue(child)
+		}
+	case []any:
+		for i, child := range value {
+			value[i] = r.redactValue(child)
+		}
+	}
+	return value
+}
+
+// sensitive reports whether the values of key are masked at the redactor's level.
+func (r *redactor) sensitive(key string) bool {
+	if anchor.IsSecretConfigKey(key) {
+		return true
+	}
+	return r.level == RedactStrict && slices.Contains(identityKeys, strings.ToLower(key))
+}
+
+// redactText masks known sensitive values and secret-looking patterns in free text.
+func (r *redactor) redactText(data []byte) []byte {
+	text := string(data)
+	for _, literal := range r.literals {
+		text = strings.ReplaceAll(text, literal, redacted)
+	}
+	text = jwtPattern.ReplaceAllString(text, redacted)
+	text = bearerPattern.ReplaceAllString(text, "${1}"+redacted)
+	text = secretPattern.ReplaceAllString(text, "${1}"+redacted)
+	if r.level == RedactStrict {
+		text = ipv4Pattern.ReplaceAllStringFunc(text, func(ip string) string {
+			// Loopback addresses identify nothing and keep local errors readable
+			if strings.HasPrefix(ip, "127.") {
+				return ip
+			}
+			return "x.x.x.x"
+		})
+		text = uuidPattern.ReplaceAllString(text, redacted)
+	}
+	return []byte(text)
+}
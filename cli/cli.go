@@ -2,7 +2,13 @@
 package cli
 
 import (
+	"errors"
+	"io"
+	"time"
+
 	"github.com/alecthomas/kong"
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/guardian"
 	"github.com/veil-net/conflux/logger"
 	"github.com/veil-net/conflux/service"
 )
@@ -10,8 +16,11 @@ import (
 // Logger re-exports the global logger for CLI use.
 var Logger = logger.Logger
 
-// CLI is the root command with run, install, start, stop, remove, status and up, down, register, unregister, info, taint subcommands.
+// CLI is the root command with run, install, start, stop, remove, status and up, down, register, unregister, info, network, taint, tracer, logs, config, credentials, healthcheck, doctor, bundle, completion subcommands, and dev in builds with the dev tag.
 type CLI struct {
+	Globals
+	DevCommands
+
 	Version kong.VersionFlag `short:"v" help:"Print the version and exit"`
 	Run     Run              `cmd:"run" default:"true" help:"Run the conflux service"`
 	Install Install          `cmd:"install" help:"Install the conflux service, this will not update registration data"`
@@ -20,16 +29,135 @@ type CLI struct {
 	Remove  Remove           `cmd:"remove" help:"Remove the conflux service, this will not update registration data"`
 	Status  Status           `cmd:"status" help:"Get the status of the conflux service"`
 
-	Up         Up         `cmd:"up" help:"Start the veilnet service with a conflux token"`
-	Down       Down       `cmd:"down" help:"Stop the veilnet service and remove the conflux token"`
-	Register   Register   `cmd:"register" help:"Register a new conflux with a registration token, and reinstall the service"`
-	Unregister Unregister `cmd:"unregister" help:"Unregister the conflux and remove the service"`
-	Info       Info       `cmd:"info" help:"Get the info of the conflux"`
-	Taint      Taint      `cmd:"taint" help:"Add or remove taints"`
+	Up          Up          `cmd:"up" help:"Start the veilnet service with a conflux token"`
+	Down        Down        `cmd:"down" help:"Stop the veilnet service and remove the conflux token"`
+	Register    Register    `cmd:"register" help:"Register a new conflux with a registration token, and reinstall the service"`
+	Unregister  Unregister  `cmd:"unregister" help:"Unregister the conflux and remove the service"`
+	Info        Info        `cmd:"info" help:"Get the info of the conflux"`
+	Network     Network     `cmd:"network" help:"Show the local and remote networks of the conflux"`
+	Taint       Taint       `cmd:"taint" help:"Add or remove taints"`
+	Tracer      Tracer      `cmd:"tracer" help:"Enable, disable or update the tracer"`
+	Logs        Logs        `cmd:"logs" help:"Show recent anchor logs"`
+	Config      Config      `cmd:"config" help:"Show the config file or the effective options with their source"`
+	Credentials Credentials `cmd:"credentials" help:"Show or migrate where the conflux token is stored"`
+
+	Healthcheck Healthcheck `cmd:"healthcheck" help:"Check the health of the running conflux, the exit code identifies the failed check"`
+	Doctor      Doctor      `cmd:"doctor" help:"Diagnose the host environment and suggest fixes"`
+	Bundle      Bundle      `cmd:"bundle" help:"Write a support bundle tarball to attach to tickets"`
+	Completion  Completion  `cmd:"completion" help:"Print a shell completion script (bash, zsh, fish, powershell)"`
+	Complete    Complete    `cmd:"" name:"__complete" hidden:"" help:"Print completion candidates for the completion scripts"`
 }
 
-// Run runs the conflux service in the foreground.
-type Run struct{}
+// Globals holds the flags shared by every command; it is bound to each command's Run method.
+type Globals struct {
+	LogLevel      string `help:"Log level (debug, info, warn, error), default: debug" env:"VEILNET_LOG_LEVEL" json:"log_level"`
+	LogFormat     string `help:"Log format (console, json), default: console" env:"VEILNET_LOG_FORMAT" json:"log_format"`
+	LogFile       string `help:"Write logs to this file with rotation instead of stderr" env:"VEILNET_LOG_FILE" json:"log_file"`
+	LogMaxSize    int    `help:"Maximum size in megabytes of the log file before it is rotated, default: 50" env:"VEILNET_LOG_MAX_SIZE" json:"log_max_size"`
+	LogMaxAge     int    `help:"Maximum number of days to keep rotated log files, default: 14" env:"VEILNET_LOG_MAX_AGE" json:"log_max_age"`
+	LogMaxBackups int    `help:"Maximum number of rotated log files to keep, default: 5" env:"VEILNET_LOG_MAX_BACKUPS" json:"log_max_backups"`
+	Output        string `short:"o" help:"Output format of read commands (json, yaml, table, template=<go template>), default: json, table for status" env:"VEILNET_OUTPUT" json:"output"`
+	NoHeaders     bool   `help:"Omit the header row of table output" env:"VEILNET_NO_HEADERS" json:"no_headers"`
+	ConfigFile    string `help:"Read options from a JSON, YAML or TOML file keyed like conflux.json; flags and environment take precedence" type:"path" env:"VEILNET_CONFIG_FILE" json:"-"`
+	StateDir      string `help:"Keep conflux.json and the conflux token in this directory instead of the OS default, e.g. a volume mounted in the container so a new container reuses the conflux" type:"path" env:"VEILNET_STATE_DIR" json:"-"`
+
+	GuardianTimeout    time.Duration `help:"Timeout of each Guardian request attempt" default:"30s" env:"VEILNET_GUARDIAN_TIMEOUT" json:"guardian_timeout"`
+	GuardianRetries    int           `help:"Retries of safe (GET) Guardian requests failing with a connection error, 429 or 5xx" default:"3" env:"VEILNET_GUARDIAN_RETRIES" json:"guardian_retries"`
+	GuardianProxy      string        `help:"Proxy URL for Guardian requests, default: HTTPS_PROXY and NO_PROXY from the environment" env:"VEILNET_GUARDIAN_PROXY" json:"guardian_proxy"`
+	GuardianCACert     string        `name:"guardian-ca-cert" help:"PEM CA bundle to trust for a self-hosted Guardian instead of the system roots" type:"path" env:"VEILNET_GUARDIAN_CA_CERT" json:"guardian_ca_cert"`
+	GuardianClientCert string        `help:"PEM client certificate presented to the Guardian for mTLS" type:"path" env:"VEILNET_GUARDIAN_CLIENT_CERT" json:"guardian_client_cert"`
+	GuardianClientKey  string        `help:"PEM client key presented to the Guardian for mTLS" type:"path" env:"VEILNET_GUARDIAN_CLIENT_KEY" json:"guardian_client_key"`
+
+	// logging is the effective logger options after merging the config file with flags and environment.
+	logging logger.Options
+}
+
+// AfterApply selects the state directory, validates the output format, fetches secret references and configures the
+// global logger and the Guardian client once flags and environment have been parsed.
+//
+// Inputs:
+//   - c: *CLI. The parsed root command.
+//   - ctx: *kong.Context. The parse context holding the secret flags of the selected command.
+//
+// Outputs:
+//   - err: error. Non-nil if the output format is invalid, a secret cannot be fetched, or the logger or Guardian options are invalid.
+func (c *CLI) AfterApply(ctx *kong.Context) error {
+	anchor.SetConfigDir(c.StateDir)
+	if err := resolveSecrets(ctx); err != nil {
+		return err
+	}
+	if c.Output != "" {
+		if err := render(io.Discard, struct{}{}, c.Output, c.NoHeaders); err != nil {
+			return err
+		}
+	}
+	if err := c.Globals.configureLogger(); err != nil {
+		return err
+	}
+	return guardian.Configure(guardian.Options{
+		Timeout:  c.GuardianTimeout,
+		Retries:  c.GuardianRetries,
+		Proxy:    c.GuardianProxy,
+		CAFile:   c.GuardianCACert,
+		CertFile: c.GuardianClientCert,
+		KeyFile:  c.GuardianClientKey,
+	})
+}
+
+// configureLogger merges the logging section of the config file with the flags and environment
+// (flags and environment win) and applies the result to the global logger.
+//
+// Inputs:
+//   - g: *Globals. The parsed global flags.
+//
+// Outputs:
+//   - err: error. Non-nil if the merged options are invalid.
+func (g *Globals) configureLogger() error {
+	options := logger.Options{}
+	if config, err := anchor.LoadConfig(); err == nil && config.Logging != nil {
+		options = *config.Logging
+	}
+	if g.LogLevel != "" {
+		options.Level = g.LogLevel
+	}
+	if g.LogFormat != "" {
+		options.Format = g.LogFormat
+	}
+	if g.LogFile != "" {
+		options.File = g.LogFile
+	}
+	if g.LogMaxSize != 0 {
+		options.MaxSizeMB = g.LogMaxSize
+	}
+	if g.LogMaxAge != 0 {
+		options.MaxAgeDays = g.LogMaxAge
+	}
+	if g.LogMaxBackups != 0 {
+		options.MaxBackups = g.LogMaxBackups
+	}
+	g.logging = options
+	return logger.Configure(options)
+}
+
+// Logging returns the effective logger options to persist in the config file.
+//
+// Inputs:
+//   - g: *Globals. The parsed global flags.
+//
+// Outputs:
+//   - *logger.Options. The merged options, or nil when nothing was configured.
+func (g *Globals) Logging() *logger.Options {
+	if g.logging == (logger.Options{}) {
+		return nil
+	}
+	options := g.logging
+	return &options
+}
+
+// Run runs the conflux service in the foreground, optionally with a TUN inherited from the parent process.
+type Run struct {
+	TUNFD int `name:"tun-fd" help:"Use the TUN at this inherited file descriptor instead of creating one, e.g. one a CNI plugin, systemd or the container runtime created so the conflux runs unprivileged (Linux and macOS)" env:"VEILNET_TUN_FD" json:"tun_fd"`
+}
 
 // Run executes the run command.
 //
@@ -37,9 +165,20 @@ type Run struct{}
 //   - cmd: *Run. The command with parsed flags.
 //
 // Outputs:
-//   - err: error. Non-nil to be reported to the user.
+//   - err: error. Non-nil to be reported to the user, e.g. if --tun-fd is not an open descriptor.
 func (cmd *Run) Run() error {
 	Logger.Sugar().Infof("Starting VeilNet Conflux...")
+	if cmd.TUNFD != 0 {
+		tun, err := anchor.OpenTUN(cmd.TUNFD)
+		if err != nil {
+			Logger.Sugar().Errorf("invalid --tun-fd: %v", err)
+			return err
+		}
+		conflux := service.NewServiceImpl()
+		conflux.UseTUN(tun)
+		conflux.Run()
+		return nil
+	}
 	conflux := service.NewService()
 	conflux.Run()
 	return nil
@@ -108,14 +247,25 @@ func (cmd *Remove) Run() error {
 // Status reports the status of the conflux service.
 type Status struct{}
 
-// Run executes the status command.
+// Run prints the service state (name, installed, running, status, PID) in the selected output format.
 //
 // Inputs:
 //   - cmd: *Status. The command with parsed flags.
+//   - globals: *Globals. Global flags selecting the output format.
 //
 // Outputs:
-//   - err: error. Non-nil to be reported to the user.
-func (cmd *Status) Run() error {
+//   - err: error. Non-nil if the state cannot be read or the service is not running.
+func (cmd *Status) Run(globals *Globals) error {
 	conflux := service.NewService()
-	return conflux.Status()
+	state, err := conflux.State()
+	if err != nil {
+		return err
+	}
+	if err := globals.Print(state, OutputTable); err != nil {
+		return err
+	}
+	if !state.Running {
+		return errors.New("the conflux service is not running")
+	}
+	return nil
 }
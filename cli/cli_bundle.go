@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/veil-net/conflux/bundle"
+	"github.com/veil-net/conflux/doctor"
+)
+
+// Bundle writes a support bundle with the redacted config, service unit and status, anchor logs, info, doctor
+// results and version.
+type Bundle struct {
+	File        string        `short:"f" help:"Path of the tarball to write, default: conflux-bundle-<time>.tar.gz in the current directory"`
+	RedactLevel string        `help:"What to mask: none, secrets (tokens, JWTs, keys, conflux ID) or strict (also other IDs, IPs and hosts, safe for tickets)" enum:"none,secrets,strict" default:"secrets"`
+	Guardian    string        `help:"The Guardian URL probed by the doctor checks, default: the one in the config file or https://guardian.veilnet.app" env:"VEILNET_GUARDIAN"`
+	Timeout     time.Duration `help:"Timeout for the anchor RPCs and each network check" default:"5s"`
+}
+
+// Run collects the bundle and writes it to the tarball.
+//
+// Inputs:
+//   - cmd: *Bundle. The output path, redaction level, Guardian URL and timeout.
+//
+// Outputs:
+//   - err: error. Non-nil if the tarball cannot be written.
+func (cmd *Bundle) Run() error {
+	path := cmd.File
+	if path == "" {
+		path = fmt.Sprintf("conflux-bundle-%s.tar.gz", time.Now().Format("20060102-150405"))
+	}
+	err := bundle.Write(context.Background(), bundle.Options{
+		Path:        path,
+		RedactLevel: bundle.RedactLevel(cmd.RedactLevel),
+		Doctor: doctor.Options{
+			Guardian: cmd.Guardian,
+			Timeout:  cmd.Timeout,
+		},
+		Timeout: cmd.Timeout,
+	})
+	if err != nil {
+		Logger.Sugar().Errorf("failed to write support bundle: %v", err)
+		return err
+	}
+	if cmd.RedactLevel == string(bundle.RedactNone) {
+		Logger.Sugar().Warnf("the bundle is not redacted, it contains the conflux token")
+	}
+	Logger.Sugar().Infof("wrote support bundle to %s", path)
+	return nil
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/alecthomas/kong"
+	"github.com/veil-net/conflux/anchor"
+)
+
+// Completion prints a shell completion script.
+type Completion struct {
+	Shell string `arg:"" help:"The shell to generate the script for (bash, zsh, fish, powershell)" enum:"bash,zsh,fish,powershell"`
+}
+
+// completionScripts are the per-shell scripts; each calls the hidden __complete command with the words typed so far.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{.Name}}, load with: source <({{.Name}} completion bash)
+_{{.Func}}() {
+    local IFS=$'\n'
+    COMPREPLY=($("${COMP_WORDS[0]}" __complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [ ${#COMPREPLY[@]} -eq 0 ]; then
+        compopt -o default
+    fi
+}
+complete -F _{{.Func}} {{.Name}}
+`,
+	"zsh": `#compdef {{.Name}}
+# zsh completion for {{.Name}}, load with: source <({{.Name}} completion zsh)
+_{{.Func}}() {
+    local -a completions
+    completions=("${(@f)$("${words[1]}" __complete -- "${(@)words[2,$CURRENT]}" 2>/dev/null)}")
+    completions=(${completions:#})
+    if (( ${#completions} )); then
+        compadd -a completions
+    else
+        _files
+    fi
+}
+compdef _{{.Func}} {{.Name}}
+`,
+	"fish": `# fish completion for {{.Name}}, load with: {{.Name}} completion fish | source
+function __{{.Func}}_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    $tokens[1] __complete -- $tokens[2..-1] 2>/dev/null
+end
+complete -c {{.Name}} -f -a '(__{{.Func}}_complete)'
+`,
+	"powershell": `# PowerShell completion for {{.Name}}, load with: {{.Name}} completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName '{{.Name}}' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '' }
+    & $commandAst.CommandElements[0].ToString() __complete -- @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+}
+
+// Run prints the completion script for the shell.
+//
+// Inputs:
+//   - cmd: *Completion. The shell.
+//   - ctx: *kong.Context. The parse context, used for the program name.
+//
+// Outputs:
+//   - err: error. Non-nil if the script cannot be rendered.
+func (cmd *Completion) Run(ctx *kong.Context) error {
+	name := ctx.Model.Name
+	tmpl := template.Must(template.New(cmd.Shell).Parse(completionScripts[cmd.Shell]))
+	return tmpl.Execute(os.Stdout, struct{ Name, Func string }{
+		Name: name,
+		Func: strings.NewReplacer("-", "_", ".", "_").Replace(name),
+	})
+}
+
+// Complete prints the completion candidates for the words typed so far; called by the completion scripts.
+type Complete struct {
+	Words []string `arg:"" optional:"" help:"The words after the program name, the last one being completed"`
+}
+
+// completionPredictors complete the positional arguments of a command, keyed by command path.
+var completionPredictors = map[string]func() []string{
+	"taint remove": completeTaints,
+}
+
+// Run walks the kong model along the typed words and prints the commands, flags or values that can follow.
+//
+// Inputs:
+//   - cmd: *Complete. The typed words.
+//   - ctx: *kong.Context. The parse context holding the kong model.
+//
+// Outputs:
+//   - err: error. Always nil; completion never fails loudly.
+func (cmd *Complete) Run(ctx *kong.Context) error {
+	for _, candidate := range completeWords(ctx.Model.Node, cmd.Words) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// completeWords returns the candidates for the last of words, starting at the root node.
+func completeWords(root *kong.Node, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	node := root
+	positionals := 0
+
+	// Walk the completed words to find the command and the flag expecting a value, if any
+	var pending *kong.Flag
+	for _, word := range words[:len(words)-1] {
+		if pending != nil {
+			pending = nil
+			continue
+		}
+		if strings.HasPrefix(word, "-") {
+			if flag := findFlag(node, word); flag != nil && !flag.IsBool() && !flag.IsCounter() && !strings.Contains(word, "=") {
+				pending = flag
+			}
+			continue
+		}
+		if child := findChild(node, word); child != nil {
+			node = child
+			positionals = 0
+			continue
+		}
+		positionals++
+	}
+
+	var candidates []string
+	switch {
+	case pending != nil:
+		candidates = pending.EnumSlice()
+	case strings.HasPrefix(current, "-"):
+		for _, group := range node.AllFlags(true) {
+			for _, flag := range group {
+				candidates = append(candidates, "--"+flag.Name)
+				if flag.Tag.Negatable == "_" {
+					candidates = append(candidates, "--no-"+flag.Name)
+				}
+				if flag.Short != 0 {
+					candidates = append(candidates, "-"+string(flag.Short))
+				}
+			}
+		}
+	default:
+		for _, child := range node.Children {
+			if !child.Hidden {
+				candidates = append(candidates, child.Name)
+			}
+		}
+		if positionals < len(node.Positional) {
+			candidates = append(candidates, node.Positional[positionals].EnumSlice()...)
+		}
+		if predictor, ok := completionPredictors[node.Path()]; ok && positionals == 0 {
+			candidates = append(candidates, predictor()...)
+		}
+	}
+
+	// Keep the candidates matching the current word
+	matching := candidates[:0]
+	for _, candidate := range candidates {
+		if candidate != "" && strings.HasPrefix(candidate, current) {
+			matching = append(matching, candidate)
+		}
+	}
+	slices.Sort(matching)
+	return slices.Compact(matching)
+}
+
+// findFlag returns the flag of node or its ancestors matching word (--name, --name=value, --no-name or -s).
+func findFlag(node *kong.Node, word string) *kong.Flag {
+	name, _, _ := strings.Cut(strings.TrimLeft(word, "-"), "=")
+	long := strings.HasPrefix(word, "--")
+	for _, group := range node.AllFlags(false) {
+		for _, flag := range group {
+			if long && (flag.Name == name || "no-"+flag.Name == name || slices.Contains(flag.Aliases, name)) {
+				return flag
+			}
+			if !long && flag.Short != 0 && string(flag.Short) == name {
+				return flag
+			}
+		}
+	}
+	return nil
+}
+
+// findChild returns the subcommand of node named word.
+func findChild(node *kong.Node, word string) *kong.Node {
+	for _, child := range node.Children {
+		if child.Type == kong.CommandNode && (child.Name == word || slices.Contains(child.Aliases, word)) {
+			return child
+		}
+	}
+	return nil
+}
+
+// completeTaints returns the taints saved in conflux.json, which the anchor applies at start.
+func completeTaints() []string {
+	config, err := anchor.LoadConfig()
+	if err != nil {
+		return nil
+	}
+	return config.Taints
+}
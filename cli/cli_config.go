@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alecthomas/kong"
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/secret"
+	"go.yaml.in/yaml/v3"
+)
+
+// configFileFlag is the name of the global flag selecting the config file.
+const configFileFlag = "config-file"
+
+// Sources of an effective option value, from highest to lowest precedence.
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceFile    = "file"
+	SourceDefault = "default"
+	SourceUnset   = "unset"
+)
+
+// BeforeResolve adds the resolver of the *_FILE secret variables, then loads the config file selected by --config-file
+// or VEILNET_CONFIG_FILE and adds it as a resolver, so its values apply to the flags that are set neither on the
+// command line nor in the environment.
+//
+// Inputs:
+//   - c: *CLI. The root command.
+//   - ctx: *kong.Context. The parse context, before resolvers run.
+//
+// Outputs:
+//   - err: error. Non-nil if the config file cannot be read, has an unsupported format or holds unknown keys.
+func (c *CLI) BeforeResolve(ctx *kong.Context) error {
+	ctx.AddResolver(secretFileResolver)
+
+	path := configFilePath(ctx)
+	if path == "" {
+		return nil
+	}
+	values, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Reject keys that match no option, a typo would otherwise be silently ignored
+	known := map[string]bool{}
+	collectConfigKeys(ctx.Model.Node, known)
+	for key := range values {
+		if !known[key] {
+			return fmt.Errorf("unknown option %q in config file %s", key, path)
+		}
+	}
+
+	ctx.AddResolver(kong.ResolverFunc(func(_ *kong.Context, _ *kong.Path, flag *kong.Flag) (any, error) {
+		// The environment takes precedence over the file
+		if _, ok := lookupFlagEnv(flag); ok {
+			return nil, nil
+		}
+		key := configKey(flag)
+		if key == "" {
+			return nil, nil
+		}
+		return values[key], nil
+	}))
+	return nil
+}
+
+// configFilePath returns the config file path given on the command line or in the environment, empty if none.
+func configFilePath(ctx *kong.Context) string {
+	for _, flag := range ctx.Model.Node.Flags {
+		if flag.Name == configFileFlag {
+			path, _ := ctx.FlagValue(flag).(string)
+			return path
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a flat config file of option keys, decoding it according to its extension.
+//
+// Inputs:
+//   - path: string. The config file (.json, .yaml, .yml or .toml).
+//
+// Outputs:
+//   - map[string]any. The option values keyed by their json name (e.g. conflux_id, otlp_endpoint).
+//   - err: error. Non-nil if the file cannot be read or decoded.
+func loadConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to read config file: %v", err)
+		return nil, err
+	}
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s: the extension must be .json, .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		Logger.Sugar().Errorf("failed to parse config file %s: %v", path, err)
+		return nil, err
+	}
+	return values, nil
+}
+
+// configKey returns the config file key of a flag, its json tag; empty if the flag cannot be set from the file.
+func configKey(flag *kong.Flag) string {
+	key, _, _ := strings.Cut(flag.Tag.Get("json"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// collectConfigKeys adds the config file keys of the flags of node and its subcommands to keys.
+func collectConfigKeys(node *kong.Node, keys map[string]bool) {
+	for _, flag := range node.Flags {
+		if key := configKey(flag); key != "" {
+			keys[key] = true
+		}
+	}
+	for _, child := range node.Children {
+		collectConfigKeys(child, keys)
+	}
+}
+
+// lookupFlagEnv returns the value of the first environment variable of flag that is set, falling back to a file:
+// reference for the *_FILE variables of secret flags.
+func lookupFlagEnv(flag *kong.Flag) (string, bool) {
+	for _, env := range flag.Envs {
+		if value, ok := os.LookupEnv(env); ok {
+			return value, true
+		}
+	}
+	return lookupSecretFile(flag)
+}
+
+// Config inspects the config file given with --config-file.
+type Config struct {
+	Show ConfigShow `cmd:"show" help:"Show the config file, or with --effective the merged options of a command and their source"`
+}
+
+// ConfigShow shows the config file or the effective options of up or register.
+type ConfigShow struct {
+	Command     string `arg:"" optional:"" default:"up" enum:"up,register" help:"The command whose effective options are shown (up, register), default: up"`
+	Effective   bool   `help:"Merge flags, environment, config file and defaults, showing the source of each value"`
+	ShowSecrets bool   `help:"Show the conflux ID, tokens and JWT instead of masking them"`
+}
+
+// ConfigValue is an option with its effective value and where the value comes from.
+type ConfigValue struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// Run prints the config file or, with --effective, one row per option of the command with its value and source.
+// Precedence is flags, then environment, then config file, then defaults; only global flags can come from the command line here.
+//
+// Inputs:
+//   - cmd: *ConfigShow. The command, effective and show-secrets flags.
+//   - ctx: *kong.Context. The parse context holding the kong model and the parsed global flags.
+//   - globals: *Globals. Global flags selecting the config file and the output format.
+//
+// Outputs:
+//   - err: error. Non-nil if no config file is given without --effective, or the config file cannot be loaded.
+func (cmd *ConfigShow) Run(ctx *kong.Context, globals *Globals) error {
+	values := map[string]any{}
+	if globals.ConfigFile != "" {
+		var err error
+		if values, err = loadConfigFile(globals.ConfigFile); err != nil {
+			return err
+		}
+	}
+
+	if !cmd.Effective {
+		if globals.ConfigFile == "" {
+			return fmt.Errorf("no config file given, set --%s or VEILNET_CONFIG_FILE", configFileFlag)
+		}
+		for key, value := range values {
+			values[key] = cmd.mask(key, value)
+		}
+		return globals.Print(values, OutputJSON)
+	}
+
+	// Find the command whose options are shown
+	var node *kong.Node
+	for _, child := range ctx.Model.Node.Children {
+		if child.Name == cmd.Command {
+			node = child
+		}
+	}
+	if node == nil {
+		return fmt.Errorf("unknown command %q", cmd.Command)
+	}
+
+	var options []ConfigValue
+	for _, group := range node.AllFlags(true) {
+		for _, flag := range group {
+			key := configKey(flag)
+			if key == "" {
+				continue
+			}
+			option := ConfigValue{Key: key}
+			if fileValue, inFile := values[key]; slices.ContainsFunc(ctx.Path, func(trace *kong.Path) bool {
+				return trace.Flag == flag && !trace.Resolved
+			}) {
+				option.Value, option.Source = ctx.FlagValue(flag), SourceFlag
+			} else if envValue, ok := lookupFlagEnv(flag); ok {
+				option.Value, option.Source = envValue, SourceEnv
+			} else if inFile {
+				option.Value, option.Source = fileValue, SourceFile
+			} else if flag.HasDefault {
+				option.Value, option.Source = flag.Default, SourceDefault
+			} else {
+				option.Source = SourceUnset
+			}
+			option.Value = cmd.mask(key, option.Value)
+			options = append(options, option)
+		}
+	}
+	return globals.Print(options, OutputTable)
+}
+
+// mask hides the value of secret options unless --show-secrets is given; references to secrets are shown as is.
+func (cmd *ConfigShow) mask(key string, value any) any {
+	if cmd.ShowSecrets || !anchor.IsSecretConfigKey(key) || value == nil || value == "" {
+		return value
+	}
+	if text, ok := value.(string); ok && secret.IsReference(text) {
+		return value
+	}
+	return "***"
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+// parseCLI parses args with a fresh root command, keeping the state in a temporary directory.
+func parseCLI(t *testing.T, args ...string) (*CLI, error) {
+	t.Helper()
+	cli := &CLI{}
+	parser, err := kong.New(cli, kong.Vars{"version": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = parser.Parse(append([]string{"--state-dir", t.TempDir()}, args...))
+	return cli, err
+}
+
+// writeConfigFile writes content to a config file named name in a temporary directory.
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	path := writeConfigFile(t, "conflux.yaml", "guardian_retries: 7\ntag: from-file\ntaints: [a, b]\nguardian: https://file.example.com\n")
+
+	t.Run("file", func(t *testing.T) {
+		cli, err := parseCLI(t, "--config-file", path, "register")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cli.GuardianRetries != 7 {
+			t.Errorf("guardian retries = %d, want 7 from the file", cli.GuardianRetries)
+		}
+		if cli.Register.Tag != "from-file" || cli.Register.Guardian != "https://file.example.com" {
+			t.Errorf("tag, guardian = %q, %q, want the file values", cli.Register.Tag, cli.Register.Guardian)
+		}
+		if !reflect.DeepEqual(cli.Register.Taints, []string{"a", "b"}) {
+			t.Errorf("taints = %v, want [a b]", cli.Register.Taints)
+		}
+	})
+
+	t.Run("env over file", func(t *testing.T) {
+		t.Setenv("VEILNET_GUARDIAN_RETRIES", "5")
+		t.Setenv("VEILNET_CONFLUX_TAG", "from-env")
+		cli, err := parseCLI(t, "--config-file", path, "register")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cli.GuardianRetries != 5 || cli.Register.Tag != "from-env" {
+			t.Errorf("guardian retries, tag = %d, %q, want the env values", cli.GuardianRetries, cli.Register.Tag)
+		}
+		if cli.Register.Guardian != "https://file.example.com" {
+			t.Errorf("guardian = %q, want the file value", cli.Register.Guardian)
+		}
+	})
+
+	t.Run("flag over env", func(t *testing.T) {
+		t.Setenv("VEILNET_GUARDIAN_RETRIES", "5")
+		t.Setenv("VEILNET_CONFLUX_TAG", "from-env")
+		cli, err := parseCLI(t, "--config-file", path, "--guardian-retries", "2", "register", "--tag", "from-flag")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cli.GuardianRetries != 2 || cli.Register.Tag != "from-flag" {
+			t.Errorf("guardian retries, tag = %d, %q, want the flag values", cli.GuardianRetries, cli.Register.Tag)
+		}
+	})
+
+	t.Run("config file from env", func(t *testing.T) {
+		t.Setenv("VEILNET_CONFIG_FILE", path)
+		cli, err := parseCLI(t, "register")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cli.Register.Tag != "from-file" {
+			t.Errorf("tag = %q, want the file value", cli.Register.Tag)
+		}
+	})
+
+	t.Run("default without file", func(t *testing.T) {
+		cli, err := parseCLI(t, "register")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cli.GuardianRetries != 3 || cli.Register.Guardian != "https://guardian.veilnet.app" {
+			t.Errorf("guardian retries, guardian = %d, %q, want the defaults", cli.GuardianRetries, cli.Register.Guardian)
+		}
+	})
+}
+
+func TestConfigFileFormats(t *testing.T) {
+	files := map[string]string{
+		"conflux.json": `{"tag": "edge", "rift": true}`,
+		"conflux.yml":  "tag: edge\nrift: true\n",
+		"conflux.toml": "tag = \"edge\"\nrift = true\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			cli, err := parseCLI(t, "--config-file", writeConfigFile(t, name, content), "register")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cli.Register.Tag != "edge" || !cli.Register.Rift {
+				t.Errorf("tag, rift = %q, %v, want edge, true", cli.Register.Tag, cli.Register.Rift)
+			}
+		})
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"unknown key", "conflux.json", `{"tga": "edge"}`, `unknown option "tga"`},
+		{"unsupported extension", "conflux.ini", "tag=edge", "unsupported config file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCLI(t, "--config-file", writeConfigFile(t, tt.file, tt.content), "register")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigShowMasksSecretKeys(t *testing.T) {
+	cmd := &ConfigShow{}
+	for _, key := range []string{"conflux_id", "conflux_token", "registration_token", "access_token", "jwt"} {
+		if got := cmd.mask(key, "value"); got != "***" {
+			t.Errorf("%s = %v, want it masked", key, got)
+		}
+	}
+	for _, key := range []string{"tag", "guardian"} {
+		if got := cmd.mask(key, "value"); got != "value" {
+			t.Errorf("%s = %v, want it shown", key, got)
+		}
+	}
+	cmd.ShowSecrets = true
+	if got := cmd.mask("conflux_id", "value"); got != "value" {
+		t.Errorf("conflux_id = %v with --show-secrets, want it shown", got)
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/veil-net/conflux/anchor"
+)
+
+// Credentials shows or migrates where the conflux token is stored via status/migrate subcommands.
+type Credentials struct {
+	Status  CredentialsStatus  `cmd:"status" help:"Show where the conflux token is stored and which secure store this host offers"`
+	Migrate CredentialsMigrate `cmd:"migrate" help:"Move a plaintext conflux token from conflux.json into the secure store of the OS"`
+}
+
+// CredentialsStatus shows where the conflux token is stored.
+type CredentialsStatus struct{}
+
+// CredentialsState describes where the token is stored and the secure store available on this host.
+type CredentialsState struct {
+	Store       string `json:"store"`
+	SecureStore string `json:"secure_store"`
+	Detail      string `json:"detail"`
+}
+
+// Run prints the store holding the token (plaintext if none) and the secure store of this host.
+//
+// Inputs:
+//   - cmd: *CredentialsStatus. The status command.
+//   - globals: *Globals. Global flags selecting the output format.
+//
+// Outputs:
+//   - err: error. Non-nil if the config cannot be loaded.
+func (cmd *CredentialsStatus) Run(globals *Globals) error {
+	config, err := anchor.LoadConfig()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to load configuration: %v", err)
+		return err
+	}
+	state := CredentialsState{Store: config.CredentialStore}
+	if state.Store == "" {
+		state.Store = anchor.CredentialStorePlaintext
+	}
+	if store, err := anchor.OpenCredentialStore(); err != nil {
+		state.Detail = err.Error()
+	} else {
+		state.SecureStore = store.Name()
+		if config.CredentialStore == "" {
+			state.Detail = "run `conflux credentials migrate` to move the token into the " + store.Name() + " store"
+		}
+	}
+	return globals.Print(state, OutputTable)
+}
+
+// CredentialsMigrate moves a plaintext token into the secure store.
+type CredentialsMigrate struct{}
+
+// Run moves the token out of conflux.json; the running service reads it from the store on its next start.
+//
+// Inputs:
+//   - cmd: *CredentialsMigrate. The migrate command.
+//
+// Outputs:
+//   - err: error. Non-nil if no secure store is usable or the config cannot be rewritten.
+func (cmd *CredentialsMigrate) Run() error {
+	store, err := anchor.MigrateCredentials()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to migrate the conflux token: %v", err)
+		return err
+	}
+	fmt.Printf("the conflux token is stored in the %s credential store\n", store)
+	return nil
+}
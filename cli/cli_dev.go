@@ -0,0 +1,177 @@
+//go:build dev
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/anchortest"
+	"github.com/veil-net/conflux/guardiantest"
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DevCommands adds the dev command to the CLI of dev builds.
+type DevCommands struct {
+	Dev Dev `cmd:"dev" help:"Serve fakes of the anchor and the Guardian for tests and local development"`
+}
+
+// Dev serves fakes of the conflux dependencies for tests and local development.
+type Dev struct {
+	Anchor   DevAnchor   `cmd:"anchor" help:"Serve a fake anchor, e.g. spawned in place of the embedded binary with VEILNET_ANCHOR_COMMAND=\"veilnet-conflux dev anchor\""`
+	Guardian DevGuardian `cmd:"guardian" help:"Serve a local Guardian stand-in with an in-memory store, to register confluxes offline with --guardian"`
+}
+
+// DevAnchor serves the fake anchor of the anchortest package until interrupted.
+type DevAnchor struct {
+	Listen         string   `help:"Address to listen on, default: VEILNET_ANCHOR_ADDRESS or 127.0.0.1:1993"`
+	ID             string   `name:"id" help:"Conflux ID returned by GetInfo"`
+	Tag            string   `help:"Conflux tag returned by GetInfo"`
+	Realm          string   `help:"Realm returned by GetRealmInfo"`
+	RealmID        string   `name:"realm-id" help:"Realm ID returned by GetRealmInfo"`
+	NoRealm        bool     `help:"Report no realm, failing the realm health check"`
+	VeilHost       string   `help:"Veil host returned by GetVeilInfo"`
+	NoVeil         bool     `help:"Report no veil connection, failing the veil health check"`
+	Taints         []string `help:"Initial taints"`
+	LocalNetworks  []string `name:"local-network" help:"Subnets returned by GetLocalNetworks"`
+	RemoteNetworks []string `name:"remote-network" help:"Peer subnets returned by GetRemoteNetworks, as <peer signature>=<subnet>"`
+	Fail           []string `help:"RPCs to fail with an unavailable error (e.g. StartAnchor, GetInfo)"`
+}
+
+// Run serves the fake anchor until SIGINT or SIGTERM.
+//
+// Inputs:
+//   - cmd: *DevAnchor. The listen address, the state overrides and the RPCs to fail.
+//
+// Outputs:
+//   - err: error. Non-nil if a remote network is invalid or the address cannot be listened on.
+func (cmd *DevAnchor) Run() error {
+	// Script the fake anchor
+	server := anchortest.NewServer()
+	info := server.Info()
+	if cmd.ID != "" {
+		info.Id = cmd.ID
+	}
+	if cmd.Tag != "" {
+		info.Tag = cmd.Tag
+	}
+	server.SetInfo(info)
+	realm := server.Realm()
+	if cmd.Realm != "" {
+		realm.Realm = cmd.Realm
+	}
+	if cmd.RealmID != "" {
+		realm.RealmId = cmd.RealmID
+	}
+	if cmd.NoRealm {
+		realm = &pb.GetRealmInfoResponse{}
+	}
+	server.SetRealm(realm)
+	veil := server.Veil()
+	if cmd.VeilHost != "" {
+		veil.VeilHost = cmd.VeilHost
+	}
+	if cmd.NoVeil {
+		veil = &pb.GetVeilInfoResponse{}
+	}
+	server.SetVeil(veil)
+	server.SetTaints(cmd.Taints)
+	local := &pb.LocalNetworks{}
+	for _, subnet := range cmd.LocalNetworks {
+		local.LocalNetworks = append(local.LocalNetworks, &pb.LocalNetwork{Subnet: subnet})
+	}
+	server.SetLocalNetworks(local)
+	remote := &pb.RemoteNetworks{}
+	for _, network := range cmd.RemoteNetworks {
+		signature, subnet, ok := strings.Cut(network, "=")
+		if !ok {
+			err := fmt.Errorf("invalid remote network %q, expected <peer signature>=<subnet>", network)
+			Logger.Sugar().Errorf("%v", err)
+			return err
+		}
+		remote.RemoteNetworks = append(remote.RemoteNetworks, &pb.RemoteNetwork{PeerSignature: signature, Subnet: subnet})
+	}
+	server.SetRemoteNetworks(remote)
+	for _, method := range cmd.Fail {
+		server.Fail(method, status.Errorf(codes.Unavailable, "%s failed by --fail", method))
+	}
+
+	// Serve until interrupted
+	listen := cmd.Listen
+	if listen == "" {
+		listen = anchor.Address()
+	}
+	address, err := server.Start(listen)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to listen on %s: %v", listen, err)
+		return err
+	}
+	defer server.Stop()
+	Logger.Sugar().Infof("fake anchor listening on %s", address)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	Logger.Sugar().Infof("fake anchor stopped")
+	return nil
+}
+
+// DevGuardian serves the Guardian stand-in of the guardiantest package until interrupted.
+type DevGuardian struct {
+	Listen          string        `help:"Address to listen on" default:"127.0.0.1:8080"`
+	AccessToken     string        `help:"Access token of the user, e.g. for register --access-token, default: guardiantest"`
+	Email           string        `help:"Email the user logs in with at /auth/login, default: dev@veilnet.local"`
+	Password        string        `help:"Password the user logs in with at /auth/login, default: guardiantest"`
+	Subnet          string        `help:"Subnet of the realm, default: 10.128.0.0/16"`
+	Tag             string        `help:"Tag of the registration token minted at startup"`
+	RegistrationTTL time.Duration `name:"registration-ttl" help:"Validity of the registration token minted at startup" default:"24h"`
+}
+
+// Run serves the Guardian stand-in until SIGINT or SIGTERM, logging its URL, access token and a registration token.
+//
+// Inputs:
+//   - cmd: *DevGuardian. The listen address, the user credentials and the realm subnet.
+//
+// Outputs:
+//   - err: error. Non-nil if the options are invalid or the address cannot be listened on.
+func (cmd *DevGuardian) Run() error {
+	server, err := guardiantest.NewServer(guardiantest.Options{
+		AccessToken: cmd.AccessToken,
+		Email:       cmd.Email,
+		Password:    cmd.Password,
+		Subnet:      cmd.Subnet,
+	})
+	if err != nil {
+		Logger.Sugar().Errorf("failed to create the Guardian stand-in: %v", err)
+		return err
+	}
+	realm := server.Realm()
+	_, registrationToken, err := server.CreateRegistrationToken(realm.ID, cmd.Tag, cmd.RegistrationTTL)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to create a registration token: %v", err)
+		return err
+	}
+
+	// Serve until interrupted
+	url, err := server.Start(cmd.Listen)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to listen on %s: %v", cmd.Listen, err)
+		return err
+	}
+	defer server.Stop()
+	Logger.Sugar().Infof("Guardian stand-in listening on %s", url)
+	Logger.Sugar().Infof("realm %s (%s), access token %s", realm.ID, realm.Subnet, server.AccessToken())
+	Logger.Sugar().Infof("registration token %s", registrationToken)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	Logger.Sugar().Infof("Guardian stand-in stopped")
+	return nil
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/veil-net/conflux/doctor"
+)
+
+// Doctor diagnoses the host environment (TUN, privileges, tools, port, config, Guardian, clock).
+type Doctor struct {
+	Guardian string        `help:"The Guardian URL to probe, default: the one in the config file or https://guardian.veilnet.app" env:"VEILNET_GUARDIAN"`
+	Timeout  time.Duration `help:"Timeout for each network check" default:"5s"`
+}
+
+// Run runs the checks for this OS and prints one row per check with a remediation hint for each problem.
+//
+// Inputs:
+//   - cmd: *Doctor. The Guardian URL and timeout.
+//   - globals: *Globals. Global flags selecting the output format; use --output json for a machine-readable report.
+//
+// Outputs:
+//   - err: error. Non-nil if any check failed.
+func (cmd *Doctor) Run(globals *Globals) error {
+	results := doctor.Run(context.Background(), doctor.Options{
+		Guardian: cmd.Guardian,
+		Timeout:  cmd.Timeout,
+	})
+	if err := globals.Print(results, OutputTable); err != nil {
+		return err
+	}
+	if failed := doctor.Failed(results); len(failed) > 0 {
+		return fmt.Errorf("%d of %d checks failed", len(failed), len(results))
+	}
+	return nil
+}
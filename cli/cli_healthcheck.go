@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+)
+
+// Healthcheck checks the running conflux and exits with a code identifying the first failed check.
+type Healthcheck struct {
+	MinTethers int           `help:"Minimum number of tethers for the conflux to be healthy" default:"0" env:"VEILNET_HEALTH_MIN_TETHERS"`
+	Timeout    time.Duration `help:"Timeout for all checks" default:"5s"`
+	Quiet      bool          `short:"q" help:"Do not print the check results, only set the exit code"`
+}
+
+// Run checks anchor reachability, GetInfo, realm, veil and tether count.
+//
+// Exit codes: 0 healthy, 2 anchor unreachable, 3 GetInfo failed, 4 no realm assigned,
+// 5 veil not connected, 6 tethers below --min-tethers.
+//
+// Inputs:
+//   - cmd: *Healthcheck. The tether threshold, timeout and quiet flag.
+//
+// Outputs:
+//   - err: error. Non-nil if unhealthy; an *anchor.HealthError carries the exit code.
+func (cmd *Healthcheck) Run() error {
+	client, err := anchor.NewAnchorClient()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
+		return &anchor.HealthError{Check: "anchor", Code: anchor.HealthAnchorUnreachable, Detail: err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.Timeout)
+	defer cancel()
+	report := anchor.CheckHealth(ctx, client, cmd.MinTethers)
+
+	if !cmd.Quiet {
+		for _, check := range report.Checks {
+			result := "ok"
+			if !check.OK {
+				result = "FAIL"
+			}
+			fmt.Printf("%-4s %-8s %s\n", result, check.Name, check.Detail)
+		}
+	}
+	return report.Err()
+}
@@ -3,15 +3,19 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/veil-net/conflux/anchor"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
-// Info shows conflux, realm, veil, tracer info, or raw ID via subcommands.
+// Info shows conflux, realm, veil, tracer info, all of them, or raw ID via subcommands.
 type Info struct {
 	Conflux InfoConflux `cmd:"conflux" default:"1" help:"Show conflux info (ID, tag, UID, CIDR, portal, public)"`
+	All     InfoAll     `cmd:"all" help:"Show conflux, realm, veil and tracer info in one document"`
 	ID      InfoID      `cmd:"id" help:"Print conflux ID only (single line, for scripts)"`
 	Realm   InfoRealm   `cmd:"realm" help:"Show realm info (realm, realm ID, subnet)"`
 	Veil    InfoVeil    `cmd:"veil" help:"Show veil connection info (host, port, region)"`
@@ -25,10 +29,11 @@ type InfoConflux struct{}
 //
 // Inputs:
 //   - cmd: *InfoConflux. The subcommand.
+//   - globals: *Globals. Global flags selecting the output format.
 //
 // Outputs:
 //   - err: error. Non-nil if the anchor client or RPC fails.
-func (cmd *InfoConflux) Run() error {
+func (cmd *InfoConflux) Run(globals *Globals) error {
 	client, err := anchor.NewAnchorClient()
 	if err != nil {
 		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
@@ -39,17 +44,7 @@ func (cmd *InfoConflux) Run() error {
 		Logger.Sugar().Errorf("failed to get conflux info: %v", err)
 		return err
 	}
-	out, err := protojson.MarshalOptions{
-		Multiline:       true,
-		Indent:          "  ",
-		UseProtoNames:   true,
-		EmitUnpopulated: true,
-	}.Marshal(info)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(out))
-	return nil
+	return globals.Print(info, OutputJSON)
 }
 
 // InfoID prints only the conflux node ID.
@@ -78,10 +73,11 @@ type InfoRealm struct{}
 //
 // Inputs:
 //   - cmd: *InfoRealm. The subcommand.
+//   - globals: *Globals. Global flags selecting the output format.
 //
 // Outputs:
 //   - err: error. Non-nil if the anchor client or RPC fails.
-func (cmd *InfoRealm) Run() error {
+func (cmd *InfoRealm) Run(globals *Globals) error {
 	client, err := anchor.NewAnchorClient()
 	if err != nil {
 		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
@@ -92,17 +88,7 @@ func (cmd *InfoRealm) Run() error {
 		Logger.Sugar().Errorf("failed to get realm info: %v", err)
 		return err
 	}
-	out, err := protojson.MarshalOptions{
-		Multiline:       true,
-		Indent:          "  ",
-		UseProtoNames:   true,
-		EmitUnpopulated: true,
-	}.Marshal(info)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(out))
-	return nil
+	return globals.Print(info, OutputJSON)
 }
 
 // InfoVeil shows veil connection info (host, port, region).
@@ -112,10 +98,11 @@ type InfoVeil struct{}
 //
 // Inputs:
 //   - cmd: *InfoVeil. The subcommand.
+//   - globals: *Globals. Global flags selecting the output format.
 //
 // Outputs:
 //   - err: error. Non-nil if the anchor client or RPC fails.
-func (cmd *InfoVeil) Run() error {
+func (cmd *InfoVeil) Run(globals *Globals) error {
 	client, err := anchor.NewAnchorClient()
 	if err != nil {
 		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
@@ -126,17 +113,7 @@ func (cmd *InfoVeil) Run() error {
 		Logger.Sugar().Errorf("failed to get veil info: %v", err)
 		return err
 	}
-	out, err := protojson.MarshalOptions{
-		Multiline:       true,
-		Indent:          "  ",
-		UseProtoNames:   true,
-		EmitUnpopulated: true,
-	}.Marshal(info)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(out))
-	return nil
+	return globals.Print(info, OutputJSON)
 }
 
 // InfoTracer shows tracer config (enabled, endpoint, use TLS, insecure, CA, cert, key).
@@ -146,10 +123,11 @@ type InfoTracer struct{}
 //
 // Inputs:
 //   - cmd: *InfoTracer. The subcommand.
+//   - globals: *Globals. Global flags selecting the output format.
 //
 // Outputs:
 //   - err: error. Non-nil if the anchor client or RPC fails.
-func (cmd *InfoTracer) Run() error {
+func (cmd *InfoTracer) Run(globals *Globals) error {
 	client, err := anchor.NewAnchorClient()
 	if err != nil {
 		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
@@ -160,15 +138,83 @@ func (cmd *InfoTracer) Run() error {
 		Logger.Sugar().Errorf("failed to get tracer config: %v", err)
 		return err
 	}
-	out, err := protojson.MarshalOptions{
-		Multiline:       true,
-		Indent:          "  ",
-		UseProtoNames:   true,
-		EmitUnpopulated: true,
-	}.Marshal(info)
+	return globals.Print(info, OutputJSON)
+}
+
+// InfoAll shows conflux, realm, veil and tracer info in one document, optionally refreshing it.
+type InfoAll struct {
+	Watch time.Duration `short:"w" help:"Refresh at this interval (e.g. 2s) until interrupted, highlighting changed tether, route and stream counts"`
+}
+
+// Run reads the four info RPCs concurrently and prints them as one document.
+//
+// Inputs:
+//   - cmd: *InfoAll. The watch interval; zero prints once.
+//   - globals: *Globals. Global flags selecting the output format.
+//
+// Outputs:
+//   - err: error. Non-nil if the anchor client or an RPC fails.
+func (cmd *InfoAll) Run(globals *Globals) error {
+	client, err := anchor.NewAnchorClient()
 	if err != nil {
+		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
 		return err
 	}
-	fmt.Println(string(out))
-	return nil
+
+	if cmd.Watch <= 0 {
+		snapshot, err := anchor.GetSnapshot(context.Background(), client)
+		if err != nil {
+			Logger.Sugar().Errorf("failed to get conflux snapshot: %v", err)
+			return err
+		}
+		return globals.Print(snapshot, OutputJSON)
+	}
+
+	// Refresh until interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(cmd.Watch)
+	defer ticker.Stop()
+	var previous *anchor.Snapshot
+	for {
+		snapshot, err := anchor.GetSnapshot(ctx, client)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Clear the screen and print the header, then the document
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("Every %s: conflux info all    %s\n\n", cmd.Watch, time.Now().Format(time.TimeOnly))
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+		} else {
+			info := snapshot.Conflux
+			var last [3]int32
+			if previous != nil {
+				last = [3]int32{previous.Conflux.GetNumberOfTethers(), previous.Conflux.GetNumberOfRoutes(), previous.Conflux.GetNumberOfStreams()}
+			}
+			fmt.Printf("tethers %s   routes %s   streams %s\n\n",
+				watchCount(info.GetNumberOfTethers(), last[0], previous != nil),
+				watchCount(info.GetNumberOfRoutes(), last[1], previous != nil),
+				watchCount(info.GetNumberOfStreams(), last[2], previous != nil))
+			if err := globals.Print(snapshot, OutputJSON); err != nil {
+				return err
+			}
+			previous = snapshot
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchCount formats a count for watch mode, highlighting it with its delta when it changed since the last refresh.
+func watchCount(current, last int32, compare bool) string {
+	if !compare || current == last {
+		return fmt.Sprintf("%d", current)
+	}
+	return fmt.Sprintf("\033[1;33m%d (%+d)\033[0m", current, current-last)
 }
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/veil-net/conflux/anchor"
+)
+
+// Logs prints the recent anchor log lines kept by the running conflux.
+type Logs struct {
+	Lines int    `short:"n" help:"Number of recent lines to print, 0 for all kept lines" default:"100"`
+	Level string `short:"l" help:"Only print lines at or above this level (debug, info, warn, error)" enum:"debug,info,warn,error" default:"debug"`
+}
+
+// logLevelRank orders anchor log levels for --level filtering.
+var logLevelRank = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3, "dpanic": 4, "panic": 5, "fatal": 6}
+
+// Run prints the persisted anchor log ring, oldest first.
+//
+// Inputs:
+//   - cmd: *Logs. The number of lines and minimum level.
+//
+// Outputs:
+//   - err: error. Non-nil if the log file cannot be read.
+func (cmd *Logs) Run() error {
+	lines, err := anchor.LoadLogs()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			Logger.Sugar().Errorf("no anchor logs found, the conflux may not have been started yet: %v", err)
+		} else {
+			Logger.Sugar().Errorf("failed to load anchor logs: %v", err)
+		}
+		return err
+	}
+
+	filtered := make([]anchor.LogLine, 0, len(lines))
+	for _, line := range lines {
+		if logLevelRank[strings.ToLower(line.Level)] >= logLevelRank[cmd.Level] {
+			filtered = append(filtered, line)
+		}
+	}
+	if cmd.Lines > 0 && len(filtered) > cmd.Lines {
+		filtered = filtered[len(filtered)-cmd.Lines:]
+	}
+
+	for _, line := range filtered {
+		fmt.Println(line.String())
+	}
+	return nil
+}
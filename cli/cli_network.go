@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/veil-net/conflux/anchor"
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Network shows the local and remote networks of the conflux via local/remote subcommands.
+type Network struct {
+	Local  NetworkLocal  `cmd:"local" help:"Show the subnets the conflux advertises, cross-checked with the Guardian given --access-token"`
+	Remote NetworkRemote `cmd:"remote" help:"Show the subnets reachable through peers with their signatures, cross-checked with the Guardian given --access-token"`
+}
+
+// NetworkLocal shows the subnets the conflux advertises.
+type NetworkLocal struct {
+	Guardian    string `help:"The Guardian URL to cross-check with, default: the one in the config file or https://guardian.veilnet.app" env:"VEILNET_GUARDIAN"`
+	AccessToken string `help:"A Guardian user access token to cross-check the networks with the Guardian, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_GUARDIAN_ACCESS_TOKEN" secret:""`
+}
+
+// Run prints the local networks of the anchor, with their agreement with the Guardian when cross-checked.
+//
+// Inputs:
+//   - cmd: *NetworkLocal. The Guardian URL and the access token for the cross-check.
+//   - globals: *Globals. Global flags selecting the output format.
+//
+// Outputs:
+//   - err: error. Non-nil if the anchor client, an RPC or the Guardian request fails, or the anchor and the Guardian
+//     disagree.
+func (cmd *NetworkLocal) Run(globals *Globals) error {
+	client, err := anchor.NewAnchorClient()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
+		return err
+	}
+	local, err := client.GetLocalNetworks(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		logNetworkError("local", err)
+		return err
+	}
+
+	// Cross-check with the Guardian
+	var known *pb.LocalNetworks
+	if cmd.AccessToken != "" {
+		guardianURL, confluxID, err := crossCheckTarget(client, cmd.Guardian)
+		if err != nil {
+			return err
+		}
+		if known, err = anchor.GuardianLocalNetworks(guardianURL, cmd.AccessToken, confluxID); err != nil {
+			Logger.Sugar().Errorf("failed to get the local networks known to the Guardian: %v", err)
+			return err
+		}
+	}
+
+	networks := anchor.CompareLocalNetworks(local, known)
+	if err := globals.Print(networks, OutputTable); err != nil {
+		return err
+	}
+	return checkAgreement(networks)
+}
+
+// NetworkRemote shows the subnets reachable through peers.
+type NetworkRemote struct {
+	Guardian    string `help:"The Guardian URL to cross-check with, default: the one in the config file or https://guardian.veilnet.app" env:"VEILNET_GUARDIAN"`
+	AccessToken string `help:"A Guardian user access token to cross-check the networks with the Guardian, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_GUARDIAN_ACCESS_TOKEN" secret:""`
+}
+
+// Run prints the remote networks of the anchor with their peer signatures, with their agreement with the Guardian
+// when cross-checked.
+//
+// Inputs:
+//   - cmd: *NetworkRemote. The Guardian URL and the access token for the cross-check.
+//   - globals: *Globals. Global flags selecting the output format.
+//
+// Outputs:
+//   - err: error. Non-nil if the anchor client, an RPC or the Guardian request fails, or the anchor and the Guardian
+//     disagree.
+func (cmd *NetworkRemote) Run(globals *Globals) error {
+	client, err := anchor.NewAnchorClient()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
+		return err
+	}
+	remote, err := client.GetRemoteNetworks(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		logNetworkError("remote", err)
+		return err
+	}
+
+	// Cross-check with the Guardian
+	var known *pb.RemoteNetworks
+	if cmd.AccessToken != "" {
+		guardianURL, confluxID, err := crossCheckTarget(client, cmd.Guardian)
+		if err != nil {
+			return err
+		}
+		if known, err = anchor.GuardianRemoteNetworks(guardianURL, cmd.AccessToken, confluxID); err != nil {
+			Logger.Sugar().Errorf("failed to get the remote networks known to the Guardian: %v", err)
+			return err
+		}
+	}
+
+	networks := anchor.CompareRemoteNetworks(remote, known)
+	if err := globals.Print(networks, OutputTable); err != nil {
+		return err
+	}
+	return checkAgreement(networks)
+}
+
+// logNetworkError logs a failed network RPC, pointing at an anchor too old to implement it.
+func logNetworkError(kind string, err error) {
+	if status.Code(err) == codes.Unimplemented {
+		Logger.Sugar().Errorf("the anchor does not report %s networks, update the conflux: %v", kind, err)
+		return
+	}
+	Logger.Sugar().Errorf("failed to get %s networks: %v", kind, err)
+}
+
+// crossCheckTarget returns the Guardian to cross-check with (the flag, else the config file, else the default) and
+// the ID of the running conflux.
+func crossCheckTarget(client pb.AnchorClient, guardianURL string) (string, string, error) {
+	info, err := client.GetInfo(context.Background(), &emptypb.Empty{})
+	if err != nil {
+		Logger.Sugar().Errorf("failed to get conflux info: %v", err)
+		return "", "", err
+	}
+	if guardianURL == "" {
+		guardianURL = anchor.DefaultGuardian
+		if config, err := anchor.LoadConfig(); err == nil && config.Guardian != "" {
+			guardianURL = config.Guardian
+		}
+	}
+	return guardianURL, info.GetId(), nil
+}
+
+// checkAgreement returns an error naming how many networks the anchor and the Guardian disagree on.
+func checkAgreement(networks []anchor.Network) error {
+	disagreements := 0
+	for _, network := range networks {
+		if network.Status != "" && network.Status != anchor.NetworkAgreed {
+			disagreements++
+		}
+	}
+	if disagreements > 0 {
+		err := fmt.Errorf("the anchor and the Guardian disagree on %d of %d networks", disagreements, len(networks))
+		Logger.Sugar().Errorf("%v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"go.yaml.in/yaml/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Output formats accepted by --output; template takes its Go template after "template=".
+const (
+	OutputJSON     = "json"
+	OutputYAML     = "yaml"
+	OutputTable    = "table"
+	OutputTemplate = "template"
+)
+
+// protoJSON marshals protobuf messages with their proto field names so output keys stay stable.
+var protoJSON = protojson.MarshalOptions{
+	UseProtoNames:   true,
+	EmitUnpopulated: true,
+}
+
+// Print writes value to stdout in the format selected by --output.
+//
+// Field names are the proto field names for protobuf messages and the json tags otherwise. A table shows
+// an object as FIELD/VALUE rows and a list as one row per item; a template is executed once per list item.
+//
+// Inputs:
+//   - g: *Globals. The parsed global flags (--output, --no-headers).
+//   - value: any. A protobuf message, a struct with json tags, or a slice of either.
+//   - fallback: string. The format used when --output is not set.
+//
+// Outputs:
+//   - err: error. Non-nil if the format is invalid or value cannot be rendered.
+func (g *Globals) Print(value any, fallback string) error {
+	format := g.Output
+	if format == "" {
+		format = fallback
+	}
+	return render(os.Stdout, value, format, g.NoHeaders)
+}
+
+// render writes value to w in format.
+func render(w io.Writer, value any, format string, noHeaders bool) error {
+	data, err := toJSON(value)
+	if err != nil {
+		return err
+	}
+
+	name, text, _ := strings.Cut(format, "=")
+	switch name {
+	case OutputJSON:
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err = out.WriteTo(w)
+		return err
+	case OutputYAML:
+		node, err := toNode(data)
+		if err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(node); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case OutputTable:
+		node, err := toNode(data)
+		if err != nil {
+			return err
+		}
+		return renderTable(w, node, noHeaders)
+	case OutputTemplate:
+		if text == "" {
+			return fmt.Errorf("output format template requires a template, e.g. template='{{.id}}'")
+		}
+		tmpl, err := template.New("output").Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid output template: %w", err)
+		}
+		var document any
+		if err := json.Unmarshal(data, &document); err != nil {
+			return err
+		}
+		items, ok := document.([]any)
+		if !ok {
+			items = []any{document}
+		}
+		for _, item := range items {
+			if err := tmpl.Execute(w, item); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q: must be json, yaml, table or template=<template>", format)
+	}
+}
+
+// toJSON marshals value to compact JSON with stable field names.
+func toJSON(value any) ([]byte, error) {
+	var data []byte
+	var err error
+	if message, ok := value.(proto.Message); ok {
+		data, err = protoJSON.Marshal(message)
+	} else if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice {
+		items := make([]json.RawMessage, rv.Len())
+		for i := range items {
+			if items[i], err = toJSON(rv.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		data, err = json.Marshal(items)
+	} else {
+		data, err = json.Marshal(value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// protojson output is deliberately unstable in whitespace, so compact it
+	var out bytes.Buffer
+	if err := json.Compact(&out, data); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// toNode parses JSON into a block-style YAML node, keeping the key order.
+func toNode(data []byte) (*yaml.Node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	var clearStyle func(node *yaml.Node)
+	clearStyle = func(node *yaml.Node) {
+		node.Style = 0
+		for _, child := range node.Content {
+			clearStyle(child)
+		}
+	}
+	clearStyle(&document)
+	if document.Kind == yaml.DocumentNode && len(document.Content) == 1 {
+		return document.Content[0], nil
+	}
+	return &document, nil
+}
+
+// renderTable writes an object as FIELD/VALUE rows (nested objects as dotted fields), or a list of objects with
+// one row per item.
+func renderTable(w io.Writer, node *yaml.Node, noHeaders bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	switch node.Kind {
+	case yaml.MappingNode:
+		if !noHeaders {
+			fmt.Fprintln(tw, "FIELD\tVALUE")
+		}
+		writeFields(tw, "", node)
+	case yaml.SequenceNode:
+		// Collect the columns in order of first appearance
+		var columns []string
+		seen := map[string]bool{}
+		for _, item := range node.Content {
+			for i := 0; item.Kind == yaml.MappingNode && i+1 < len(item.Content); i += 2 {
+				if key := item.Content[i].Value; !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+			}
+		}
+		if len(columns) == 0 {
+			for _, item := range node.Content {
+				fmt.Fprintln(tw, cellValue(item))
+			}
+			break
+		}
+		if !noHeaders {
+			headers := make([]string, len(columns))
+			for i, column := range columns {
+				headers[i] = strings.ToUpper(column)
+			}
+			fmt.Fprintln(tw, strings.Join(headers, "\t"))
+		}
+		for _, item := range node.Content {
+			cells := make([]string, len(columns))
+			for i := 0; i+1 < len(item.Content); i += 2 {
+				for j, column := range columns {
+					if column == item.Content[i].Value {
+						cells[j] = cellValue(item.Content[i+1])
+					}
+				}
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	default:
+		fmt.Fprintln(tw, cellValue(node))
+	}
+	return tw.Flush()
+}
+
+// writeFields writes one FIELD/VALUE row per field of a mapping node, descending into nested mappings.
+func writeFields(w io.Writer, prefix string, node *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := prefix+node.Content[i].Value, node.Content[i+1]
+		if value.Kind == yaml.MappingNode {
+			writeFields(w, key+".", value)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\n", key, cellValue(value))
+	}
+}
+
+// cellValue renders a node as a single table cell; nested values are shown as compact JSON.
+func cellValue(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		if node.Tag == "!!null" {
+			return ""
+		}
+		return node.Value
+	}
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -2,103 +2,147 @@ package cli
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/veil-net/conflux/anchor"
-	pb "github.com/veil-net/conflux/proto"
+	"github.com/veil-net/conflux/oidc"
 	"github.com/veil-net/conflux/service"
+	"github.com/veil-net/conflux/telemetry"
 )
 
-// Register registers a new conflux with a registration token and options (rift, portal, guardian, tag, IP, JWT/JWKS, taints, tracer, debug).
+// Register registers a new conflux, or reuses the existing one, with a registration token and options (rift, portal, guardian, tag, IP, JWT/JWKS, OIDC login or workload identity, taints, tracer, debug, HTTP monitor, force, ephemeral).
 type Register struct {
-	RegistrationToken string   `short:"t" help:"The registration token" env:"VEILNET_REGISTRATION_TOKEN" json:"registration_token"`
-	Rift              bool     `short:"r" help:"Enable rift mode, default: false" default:"false" env:"VEILNET_CONFLUX_RIFT" json:"rift"`
-	Portal            bool     `short:"p" help:"Enable portal mode, default: false" default:"false" env:"VEILNET_CONFLUX_PORTAL" json:"portal"`
-	Conduit           bool     `short:"c" help:"Enable conduit mode, default: false" default:"false" env:"VEILNET_CONFLUX_CONDUIT" json:"conduit"`
-	Guardian          string   `help:"The Guardian URL (Authentication Server), default: https://guardian.veilnet.app" default:"https://guardian.veilnet.app" env:"VEILNET_GUARDIAN" json:"guardian"`
-	Tag               string   `help:"The tag for the conflux" env:"VEILNET_CONFLUX_TAG" json:"tag"`
-	IP                string   `help:"The IP of the conflux" env:"VEILNET_CONFLUX_IP" json:"ip"`
-	JWT               string   `help:"The JWT for the conflux" env:"VEILNET_CONFLUX_JWT" json:"jwt"`
-	JWKS_url          string   `help:"The JWKS URL for the conflux" env:"VEILNET_CONFLUX_JWKS_URL" json:"jwks_url"`
-	Audience          string   `help:"The audience for the conflux" env:"VEILNET_CONFLUX_AUDIENCE" json:"audience"`
-	Issuer            string   `help:"The issuer for the conflux" env:"VEILNET_CONFLUX_ISSUER" json:"issuer"`
-	Taints            []string `help:"Taints for the conflux, conflux can only communicate with other conflux with taints that are either a super set or a subset" env:"VEILNET_CONFLUX_TAINTS" json:"taints"`
-	Debug             bool     `short:"d" help:"Enable debug mode, this will not install the service but run conflux directly" env:"VEILNET_CONFLUX_DEBUG" json:"debug"`
-	Tracer            bool     `help:"Enable tracer, default: false" default:"false" env:"VEILNET_TRACER" json:"tracer"`
-	OTLPEndpoint      string   `help:"The OTLP endpoint for the metrics" env:"VEILNET_OTLP_ENDPOINT" json:"otlp_endpoint"`
-	OTLPUseTLS        bool     `help:"Enable TLS for the metrics" default:"false" env:"VEILNET_OTLP_USE_TLS" json:"otlp_use_tls"`
-	OTLPInsecure      bool     `help:"Enable insecure mode for the metrics" default:"false" env:"VEILNET_OTLP_INSECURE" json:"otlp_insecure"`
-	OTLPCACert        string   `help:"The OTLP CA certificate for the metrics" env:"VEILNET_OTLP_CA_CERT" json:"otlp_ca_cert"`
-	OTLPClientCert    string   `help:"The OTLP client certificate for the metrics" env:"VEILNET_OTLP_CLIENT_CERT" json:"otlp_client_cert"`
-	OTLPClientKey     string   `help:"The OTLP client key for the metrics" env:"VEILNET_OTLP_CLIENT_KEY" json:"otlp_client_key"`
+	RegistrationToken string            `short:"t" help:"The registration token, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_REGISTRATION_TOKEN" secret:"" json:"registration_token"`
+	Rift              bool              `short:"r" help:"Enable rift mode, default: false" default:"false" env:"VEILNET_CONFLUX_RIFT" json:"rift"`
+	Portal            bool              `short:"p" help:"Enable portal mode, default: false" default:"false" env:"VEILNET_CONFLUX_PORTAL" json:"portal"`
+	Conduit           bool              `short:"c" help:"Enable conduit mode, default: false" default:"false" env:"VEILNET_CONFLUX_CONDUIT" json:"conduit"`
+	Guardian          string            `help:"The Guardian URL (Authentication Server), default: https://guardian.veilnet.app" default:"https://guardian.veilnet.app" env:"VEILNET_GUARDIAN" json:"guardian"`
+	Tag               string            `help:"The tag for the conflux" env:"VEILNET_CONFLUX_TAG" json:"tag"`
+	IP                string            `help:"The IP of the conflux" env:"VEILNET_CONFLUX_IP" json:"ip"`
+	JWT               string            `help:"The JWT for the conflux, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_CONFLUX_JWT" secret:"" xor:"identity" json:"jwt"`
+	JWKS_url          string            `help:"The JWKS URL for the conflux" env:"VEILNET_CONFLUX_JWKS_URL" json:"jwks_url"`
+	Audience          string            `help:"The audience for the conflux" env:"VEILNET_CONFLUX_AUDIENCE" json:"audience"`
+	Issuer            string            `help:"The issuer for the conflux" env:"VEILNET_CONFLUX_ISSUER" json:"issuer"`
+	OIDCIssuer        string            `name:"oidc-issuer" help:"Log in at this OpenID Connect issuer and register with the ID token, the JWKS URL, issuer and audience default to the discovered ones" env:"VEILNET_OIDC_ISSUER" json:"oidc_issuer" xor:"identity"`
+	ClientID          string            `help:"The OIDC client ID used with --oidc-issuer, the audience of the ID token" env:"VEILNET_OIDC_CLIENT_ID" json:"client_id"`
+	OIDCFlow          string            `name:"oidc-flow" help:"The OIDC login flow: device (enter a code in any browser) or browser (loopback redirect with PKCE on this host)" enum:"device,browser" default:"device" env:"VEILNET_OIDC_FLOW" json:"oidc_flow"`
+	OIDCScopes        []string          `name:"oidc-scopes" help:"The OIDC scopes to request, default: openid" env:"VEILNET_OIDC_SCOPES" json:"oidc_scopes"`
+	IdentityTokenFile string            `help:"Register with the workload identity JWT in this file, e.g. a projected Kubernetes service account token; the issuer, audience and JWKS URL default to its claims and discovery" type:"path" env:"VEILNET_IDENTITY_TOKEN_FILE" json:"identity_token_file" xor:"identity"`
+	IdentityURL       string            `name:"identity-url" help:"Register with the instance identity JWT fetched from this cloud metadata URL; the issuer, audience and JWKS URL default to its claims and discovery" env:"VEILNET_IDENTITY_URL" json:"identity_url" xor:"identity"`
+	IdentityHeaders   map[string]string `help:"Headers sent to --identity-url (e.g. Metadata-Flavor=Google)" env:"VEILNET_IDENTITY_HEADERS" json:"identity_headers"`
+	Taints            []string          `help:"Taints for the conflux, conflux can only communicate with other conflux with taints that are either a super set or a subset" env:"VEILNET_CONFLUX_TAINTS" json:"taints"`
+	Debug             bool              `short:"d" help:"Enable debug mode, this will not install the service but run conflux directly" env:"VEILNET_CONFLUX_DEBUG" json:"debug"`
+	HTTPAddr          string            `help:"Listen address of the service HTTP endpoint serving /metrics, /healthz and /readyz (e.g. 127.0.0.1:9193), disabled when empty" env:"VEILNET_HTTP_ADDR" json:"http_addr"`
+	Force             bool              `help:"Register a new conflux even if conflux.json or the Guardian already holds one for this host or tag" env:"VEILNET_CONFLUX_FORCE" json:"force"`
+	Ephemeral         bool              `help:"Register a new conflux for this run only: it runs in the foreground like debug mode, is unregistered on interrupt or SIGTERM, and one left behind by a crash is unregistered by the next ephemeral run" env:"VEILNET_CONFLUX_EPHEMERAL" json:"ephemeral"`
+	AccessToken       string            `help:"A Guardian user access token to check that the conflux of conflux.json still exists, and that no conflux holds --tag before registering a new one, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_GUARDIAN_ACCESS_TOKEN" secret:"" json:"access_token"`
+
+	TracerFlags
 }
 
+// oidcLoginTimeout bounds an OIDC login, including the time the user takes to approve it.
+const oidcLoginTimeout = 10 * time.Minute
+
+// workloadIdentityTimeout bounds fetching the workload identity token and discovering its issuer.
+const workloadIdentityTimeout = 30 * time.Second
+
 // ConfluxToken holds conflux ID and token (e.g. from registration response).
 type ConfluxToken struct {
 	ConfluxID string `json:"conflux_id"`
 	Token     string `json:"token"`
 }
 
-// Run registers the conflux, saves config, and either installs the service or runs the anchor in debug mode.
+// Run registers the conflux, or reuses the conflux of an existing conflux.json unless --force is given, saves config, and either installs the service or runs the anchor in debug mode. An ephemeral conflux is
+// always new, is not saved, and is unregistered when the anchor stops.
 //
 // Inputs:
-//   - cmd: *Register. Registration token, guardian, tag, IP, JWT/JWKS, taints, tracer options, debug.
+//   - cmd: *Register. Registration token, guardian, tag, IP, JWT/JWKS, OIDC login or workload identity, taints, tracer options, debug, force, ephemeral.
+//   - globals: *Globals. Global flags; the effective logging options are saved with the config, debug mode saves it too when a state directory is set.
 //
 // Outputs:
-//   - err: error. Non-nil if registration, config save, service install, or anchor start fails.
-func (cmd *Register) Run() error {
+//   - err: error. Non-nil if the existing conflux cannot be reused, or the OIDC login, workload identity, registration, config save, service install, anchor start, or ephemeral unregistration fails.
+func (cmd *Register) Run(globals *Globals) error {
+	if cmd.Ephemeral {
+		cmd.Debug = true
+	}
+	if globals.StateDir != "" && !cmd.Debug {
+		err := errors.New("--state-dir requires debug mode, the installed service reads the default config directory")
+		Logger.Sugar().Errorf("invalid options: %v", err)
+		return err
+	}
 
-	// Parse the command
-	registrationRequest := &anchor.ResgitrationRequest{
-		RegistrationToken: cmd.RegistrationToken,
-		Guardian:          cmd.Guardian,
-		Tag:               cmd.Tag,
-		JWT:               cmd.JWT,
-		JWKS_url:          cmd.JWKS_url,
-		Audience:          cmd.Audience,
-		Issuer:            cmd.Issuer,
+	tracerConfig := cmd.TracerConfig()
+	if err := tracerConfig.Validate(); err != nil {
+		Logger.Sugar().Errorf("invalid tracer config: %v", err)
+		return err
 	}
 
-	// Register the conflux
-	registrationResponse, err := anchor.RegisterConflux(registrationRequest)
-	if err != nil {
-		Logger.Sugar().Errorf("failed to register conflux: %v", err)
+	// A portal is only unregistered with the registration token, the conflux token only logs it out
+	if cmd.Ephemeral && cmd.Portal && cmd.RegistrationToken == "" {
+		err := errors.New("an ephemeral portal requires --registration-token to unregister it")
+		Logger.Sugar().Errorf("invalid options: %v", err)
 		return err
 	}
 
-	// Save the configuration
-	tracerConfig := &anchor.TracerConfig{
-		Enabled:  cmd.Tracer,
-		UseTLS:   cmd.OTLPUseTLS,
-		Endpoint: cmd.OTLPEndpoint,
-		Insecure: cmd.OTLPInsecure,
-		CAFile:   cmd.OTLPCACert,
-		CertFile: cmd.OTLPClientCert,
-		KeyFile:  cmd.OTLPClientKey,
-	}
-	config := &anchor.ConfluxConfig{
-		ConfluxID: registrationResponse.ConfluxID,
-		Token:     registrationResponse.Token,
-		Guardian:  cmd.Guardian,
-		Rift:      cmd.Rift,
-		Portal:    cmd.Portal,
-		Conduit:   cmd.Conduit,
-		IP:        cmd.IP,
-		Taints:    cmd.Taints,
-		Tracer:    tracerConfig,
+	// Unregister the confluxes crashed ephemeral runs left behind
+	if cmd.Ephemeral {
+		cmd.cleanupEphemeral()
 	}
 
-	if !cmd.Debug {
+	// Reuse the conflux of the existing configuration
+	var existing *anchor.ConfluxConfig
+	if !cmd.Force && !cmd.Ephemeral {
+		var err error
+		if existing, err = cmd.existingConflux(); err != nil {
+			Logger.Sugar().Errorf("failed to reuse the existing conflux, use --force to register a new one: %v", err)
+			return err
+		}
+	}
+
+	// Register the conflux
+	config := existing
+	if config != nil {
+		Logger.Sugar().Infof("reusing conflux %s from the existing configuration", config.ConfluxID)
+	} else {
+		registrationResponse, err := cmd.register(tracerConfig)
+		if err != nil {
+			return err
+		}
+		// The installed service counts the registration, a debug run already counted it in this process
+		config = &anchor.ConfluxConfig{
+			ConfluxID:  registrationResponse.ConfluxID,
+			Token:      registrationResponse.Token,
+			Registered: !cmd.Debug,
+		}
+	}
+	if config.Tag == "" {
+		config.Tag = cmd.Tag
+	}
+	config.Guardian = cmd.Guardian
+	config.Rift = cmd.Rift
+	config.Portal = cmd.Portal
+	config.Conduit = cmd.Conduit
+	config.IP = cmd.IP
+	config.Taints = cmd.Taints
+	config.Tracer = tracerConfig
+	config.Logging = globals.Logging()
+	config.HTTPAddr = cmd.HTTPAddr
+
+	if !cmd.Debug || (globals.StateDir != "" && !cmd.Ephemeral) {
 		// Save the configuration
-		err = anchor.SaveConfig(config)
+		err := anchor.SaveConfig(config)
 		if err != nil {
 			Logger.Sugar().Errorf("failed to save configuration: %v", err)
 			return err
 		}
+	}
 
+	if !cmd.Debug {
 		// Install the service
 		conflux := service.NewService()
 		if err := conflux.Status(); err == nil {
@@ -107,7 +151,7 @@ func (cmd *Register) Run() error {
 		} else {
 			Logger.Sugar().Infof("installing veilnet conflux service...")
 		}
-		err = conflux.Install()
+		err := conflux.Install()
 		if err != nil {
 			Logger.Sugar().Errorf("failed to install service: %v", err)
 			return err
@@ -115,65 +159,320 @@ func (cmd *Register) Run() error {
 		return nil
 	}
 
-	// Initialize the anchor plugin
-	subprocess, err := anchor.NewAnchor()
+	// Record the ephemeral conflux, locked for this run, so a later run unregisters it if this one crashes
+	var record *anchor.EphemeralRecord
+	if cmd.Ephemeral {
+		record = &anchor.EphemeralRecord{
+			ConfluxID:    config.ConfluxID,
+			Guardian:     config.Guardian,
+			Token:        config.Token,
+			Portal:       config.Portal,
+			RegisteredAt: time.Now().UTC(),
+		}
+		if config.Portal {
+			record.RegistrationToken = cmd.RegistrationToken
+		}
+		release, err := anchor.LockEphemeral(config.ConfluxID)
+		if err == nil {
+			defer release()
+			err = anchor.SaveEphemeralRecord(record)
+		}
+		if err != nil {
+			Logger.Sugar().Warnf("failed to save the ephemeral cleanup record, a crash would leave conflux %s registered: %v", config.ConfluxID, err)
+		}
+	}
+
+	// Run the anchor in the foreground until interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := service.NewServiceImpl().ServeConfig(ctx, config, nil)
+
+	// Unregister the ephemeral conflux
+	if cmd.Ephemeral {
+		Logger.Sugar().Infof("unregistering ephemeral conflux %s", config.ConfluxID)
+		if unregisterErr := anchor.UnregisterEphemeral(record); unregisterErr != nil {
+			Logger.Sugar().Errorf("failed to unregister ephemeral conflux %s, the next ephemeral run retries: %v", config.ConfluxID, unregisterErr)
+			return errors.Join(err, unregisterErr)
+		}
+	}
+	return err
+}
+
+// cleanupEphemeral unregisters the confluxes of the cleanup records crashed ephemeral runs left behind, skipping the
+// records locked by a running process; failures are logged and the record is kept for the next run.
+//
+// Inputs:
+//   - cmd: *Register. The Guardian.
+//
+// Outputs: none.
+func (cmd *Register) cleanupEphemeral() {
+	confluxIDs, err := anchor.EphemeralConfluxes()
 	if err != nil {
-		Logger.Sugar().Errorf("failed to initialize anchor subprocess: %v", err)
-		return err
+		Logger.Sugar().Warnf("failed to list the ephemeral cleanup records: %v", err)
+		return
+	}
+	for _, confluxID := range confluxIDs {
+		cmd.cleanupEphemeralConflux(confluxID)
 	}
+}
 
-	// Wait for the subprocess to start
-	time.Sleep(1 * time.Second)
+// cleanupEphemeralConflux unregisters the conflux of one cleanup record unless a running process holds its lock.
+func (cmd *Register) cleanupEphemeralConflux(confluxID string) {
+	release, err := anchor.LockEphemeral(confluxID)
+	if errors.Is(err, anchor.ErrEphemeralInUse) {
+		return
+	}
+	if err != nil {
+		Logger.Sugar().Warnf("failed to lock the ephemeral cleanup record of conflux %s: %v", confluxID, err)
+		return
+	}
+	defer release()
 
-	// Create a gRPC client connection
-	anchor, err := anchor.NewAnchorClient()
+	// Read the record again under the lock, its run may have removed it in the meantime
+	record, err := anchor.LoadEphemeralRecord(confluxID)
 	if err != nil {
-		subprocess.Process.Kill()
-		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
-		return err
+		Logger.Sugar().Warnf("failed to read the ephemeral cleanup record of conflux %s: %v", confluxID, err)
+		return
+	}
+	if record == nil {
+		return
 	}
+	if record.Guardian != cmd.Guardian {
+		Logger.Sugar().Warnf("ephemeral conflux %s left behind by a previous run is registered with %s, not %s, unregister it there", record.ConfluxID, record.Guardian, cmd.Guardian)
+		return
+	}
+	Logger.Sugar().Infof("unregistering ephemeral conflux %s left behind by a previous run registered at %s", record.ConfluxID, record.RegisteredAt.Format(time.RFC3339))
+	if err := anchor.UnregisterEphemeral(record); err != nil {
+		Logger.Sugar().Warnf("failed to unregister ephemeral conflux %s: %v", record.ConfluxID, err)
+	}
+}
 
-	// Start the anchor
-	_, err = anchor.StartAnchor(context.Background(), &pb.StartAnchorRequest{
-		GuardianUrl: config.Guardian,
-		AnchorToken: config.Token,
-		Ip:          config.IP,
-		Portal:      config.Portal,
-		Rift:        config.Rift,
-		Conduit:     config.Conduit,
-		Tracer: &pb.TracerConfig{
-			Enabled:  config.Tracer.Enabled,
-			Endpoint: config.Tracer.Endpoint,
-			UseTls:   config.Tracer.UseTLS,
-			Insecure: config.Tracer.Insecure,
-			Ca:       config.Tracer.CAFile,
-			Cert:     config.Tracer.CertFile,
-			Key:      config.Tracer.KeyFile,
-		},
-	})
+// existingConflux loads the conflux of conflux.json if it was registered with the same Guardian and tag; with an
+// access token the Guardian must still list it.
+//
+// Inputs:
+//   - cmd: *Register. The Guardian, tag and access token.
+//
+// Outputs:
+//   - *anchor.ConfluxConfig. The existing config, nil if there is no conflux.json.
+//   - err: error. Non-nil if conflux.json cannot be read, has no identity, belongs to another Guardian or tag, or
+//     the Guardian cannot be asked or no longer lists the conflux.
+func (cmd *Register) existingConflux() (*anchor.ConfluxConfig, error) {
+	config, err := anchor.LoadConfig()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
-		subprocess.Process.Kill()
-		Logger.Sugar().Errorf("failed to start anchor: %v", err)
-		return err
+		return nil, err
+	}
+	if config.ConfluxID == "" || config.Token == "" {
+		return nil, errors.New("conflux.json has no conflux ID or token")
+	}
+	if config.Guardian != cmd.Guardian {
+		return nil, fmt.Errorf("conflux %s is registered with %s, not %s", config.ConfluxID, config.Guardian, cmd.Guardian)
+	}
+	if cmd.Tag != "" && config.Tag != "" && config.Tag != cmd.Tag {
+		return nil, fmt.Errorf("conflux %s is tagged %q, not %q", config.ConfluxID, config.Tag, cmd.Tag)
 	}
 
-	// Add taints
-	for _, taint := range config.Taints {
-		_, err = anchor.AddTaint(context.Background(), &pb.AddTaintRequest{
-			Taint: taint,
-		})
+	// Check the Guardian still holds the conflux
+	if cmd.AccessToken == "" {
+		Logger.Sugar().Warnf("reusing conflux %s without checking the Guardian still holds it, set --access-token to check", config.ConfluxID)
+		return config, nil
+	}
+	confluxes, err := anchor.ListConfluxes(cmd.Guardian, cmd.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check conflux %s with the Guardian: %w", config.ConfluxID, err)
+	}
+	for _, conflux := range confluxes {
+		if conflux.ID == config.ConfluxID {
+			return config, nil
+		}
+	}
+	return nil, fmt.Errorf("conflux %s of conflux.json is no longer registered with %s", config.ConfluxID, cmd.Guardian)
+}
+
+// register obtains the JWT if an identity provider is configured and registers the conflux. With an access token
+// and a tag, the registration is refused if the Guardian already holds a conflux with the tag, since the Guardian
+// offers no way to obtain the token of an existing conflux.
+//
+// Inputs:
+//   - cmd: *Register. The registration options.
+//   - tracerConfig: *anchor.TracerConfig. The validated tracer config exporting the registration span.
+//
+// Outputs:
+//   - *anchor.RegistrationResponse. The conflux ID and token.
+//   - err: error. Non-nil if the lookup, OIDC login, workload identity or registration fails, or the tag is taken.
+func (cmd *Register) register(tracerConfig *anchor.TracerConfig) (*anchor.RegistrationResponse, error) {
+	// Parse the command
+	registrationRequest := &anchor.ResgitrationRequest{
+		RegistrationToken: cmd.RegistrationToken,
+		Guardian:          cmd.Guardian,
+		Tag:               cmd.Tag,
+		JWT:               cmd.JWT,
+		JWKS_url:          cmd.JWKS_url,
+		Audience:          cmd.Audience,
+		Issuer:            cmd.Issuer,
+	}
+
+	// Look up the conflux registered with the tag
+	if !cmd.Force && !cmd.Ephemeral && cmd.AccessToken != "" && cmd.Tag != "" {
+		conflux, err := anchor.FindConflux(cmd.Guardian, cmd.AccessToken, cmd.Tag)
 		if err != nil {
-			Logger.Sugar().Warnf("failed to add taint: %v", err)
-			continue
+			Logger.Sugar().Errorf("failed to look up the conflux tagged %q, use --force to register a new one: %v", cmd.Tag, err)
+			return nil, err
+		}
+		if conflux != nil {
+			err := fmt.Errorf("conflux %s tagged %q is already registered and its token is only in its conflux.json", conflux.ID, cmd.Tag)
+			Logger.Sugar().Errorf("failed to reuse the conflux tagged %q, restore its conflux.json, unregister it or use --force to register a new one: %v", cmd.Tag, err)
+			return nil, err
 		}
 	}
 
-	// Wait for interrupt signal
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	// Log in at the OIDC provider for the JWT
+	if cmd.OIDCIssuer != "" {
+		if err := cmd.oidcLogin(registrationRequest); err != nil {
+			Logger.Sugar().Errorf("failed to log in at %s: %v", cmd.OIDCIssuer, err)
+			return nil, err
+		}
+	}
 
-	// Wait for interrupt signal
-	<-interrupt
+	// Read the workload identity the platform provides for the JWT
+	if cmd.IdentityTokenFile != "" || cmd.IdentityURL != "" {
+		if err := cmd.workloadIdentity(registrationRequest); err != nil {
+			Logger.Sugar().Errorf("failed to get the workload identity: %v", err)
+			return nil, err
+		}
+	}
 
+	// Export the registration span with the tracer settings
+	shutdown, err := telemetry.Setup(context.Background(), tracerConfig.Telemetry())
+	if err != nil {
+		Logger.Sugar().Warnf("failed to set up telemetry, continuing without it: %v", err)
+	}
+
+	// Register the conflux
+	registrationResponse, err := anchor.RegisterConflux(registrationRequest)
+	flushTelemetry(shutdown)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to register conflux: %v", err)
+		return nil, err
+	}
+	return registrationResponse, nil
+}
+
+// flushTelemetry exports pending telemetry and shuts the providers down, waiting at most 5 seconds.
+//
+// Inputs:
+//   - shutdown: func(context.Context) error. The shutdown function returned by telemetry.Setup.
+//
+// Outputs: none.
+func flushTelemetry(shutdown func(context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdown(ctx); err != nil {
+		Logger.Sugar().Warnf("failed to flush telemetry: %v", err)
+	}
+}
+
+// oidcLogin logs in at the OIDC issuer with the selected flow and fills the JWT of the request with the ID token,
+// and its JWKS URL, issuer and audience with the discovered ones unless they were given.
+//
+// Inputs:
+//   - cmd: *Register. The OIDC issuer, client ID, flow and scopes.
+//   - request: *anchor.ResgitrationRequest. The registration request to fill.
+//
+// Outputs:
+//   - err: error. Non-nil if discovery or the login fails.
+func (cmd *Register) oidcLogin(request *anchor.ResgitrationRequest) error {
+	if cmd.ClientID == "" {
+		return errors.New("--client-id is required with --oidc-issuer")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oidcLoginTimeout)
+	defer cancel()
+
+	config := &oidc.Config{Issuer: cmd.OIDCIssuer, ClientID: cmd.ClientID, Scopes: cmd.OIDCScopes}
+	provider, err := oidc.Discover(ctx, config)
+	if err != nil {
+		return err
+	}
+	var token *oidc.Token
+	switch cmd.OIDCFlow {
+	case "browser":
+		token, err = oidc.BrowserLogin(ctx, config, provider, func(url string) error {
+			fmt.Fprintf(os.Stderr, "Opening the browser to log in, if it does not open visit:\n\n  %s\n\n", url)
+			if err := oidc.OpenBrowser(url); err != nil {
+				Logger.Sugar().Warnf("failed to open the browser: %v", err)
+			}
+			return nil
+		})
+	default:
+		token, err = oidc.DeviceLogin(ctx, config, provider, func(code *oidc.DeviceCode) {
+			if code.VerificationURIComplete != "" {
+				fmt.Fprintf(os.Stderr, "To log in, visit:\n\n  %s\n\nand confirm the code %s\n\n", code.VerificationURIComplete, code.UserCode)
+				return
+			}
+			fmt.Fprintf(os.Stderr, "To log in, visit:\n\n  %s\n\nand enter the code %s\n\n", code.VerificationURI, code.UserCode)
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	request.JWT = token.IDToken
+	if request.JWKS_url == "" {
+		request.JWKS_url = provider.JWKSURI
+	}
+	if request.Issuer == "" {
+		request.Issuer = provider.Issuer
+	}
+	if request.Audience == "" {
+		request.Audience = cmd.ClientID
+	}
+	return nil
+}
+
+// workloadIdentity reads the workload identity JWT from the token file or the metadata URL and fills the request with
+// it; the issuer and audience default to its claims and the JWKS URL to the discovery document of its issuer.
+//
+// Inputs:
+//   - cmd: *Register. The identity token file or metadata URL and headers.
+//   - request: *anchor.ResgitrationRequest. The registration request to fill.
+//
+// Outputs:
+//   - err: error. Non-nil if the token cannot be read, is not a JWT, or the JWKS URL cannot be discovered.
+func (cmd *Register) workloadIdentity(request *anchor.ResgitrationRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), workloadIdentityTimeout)
+	defer cancel()
+
+	var token string
+	var err error
+	if cmd.IdentityTokenFile != "" {
+		token, err = oidc.ReadTokenFile(cmd.IdentityTokenFile)
+	} else {
+		token, err = oidc.FetchToken(ctx, &oidc.Config{}, cmd.IdentityURL, cmd.IdentityHeaders)
+	}
+	if err != nil {
+		return err
+	}
+	claims, err := oidc.ParseClaims(token)
+	if err != nil {
+		return err
+	}
+
+	request.JWT = token
+	if request.Issuer == "" {
+		request.Issuer = claims.Issuer
+	}
+	if request.Audience == "" && len(claims.Audience) > 0 {
+		request.Audience = claims.Audience[0]
+	}
+	if request.JWKS_url == "" {
+		provider, err := oidc.Discover(ctx, &oidc.Config{Issuer: claims.Issuer})
+		if err != nil {
+			return fmt.Errorf("failed to discover the JWKS URL of %s, set --jwks-url: %w", claims.Issuer, err)
+		}
+		request.JWKS_url = provider.JWKSURI
+	}
 	return nil
 }
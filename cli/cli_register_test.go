@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/guardiantest"
+)
+
+// testGuardian serves a Guardian stand-in for the test and returns it with its URL and a registration token.
+func testGuardian(t *testing.T) (*guardiantest.Server, string, string) {
+	t.Helper()
+	anchor.SetConfigDir(t.TempDir())
+	t.Cleanup(func() { anchor.SetConfigDir("") })
+	t.Setenv(anchor.CredentialStoreEnv, anchor.CredentialStorePlaintext)
+
+	server, err := guardiantest.NewServer(guardiantest.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := server.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	_, token, err := server.CreateRegistrationToken(server.Realm().ID, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, url, token
+}
+
+func TestRegisterRefusesTakenTag(t *testing.T) {
+	server, url, token := testGuardian(t)
+	first := &Register{RegistrationToken: token, Guardian: url, Tag: "edge"}
+	created, err := first.register(first.TracerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without conflux.json, the access token finds the conflux holding the tag
+	second := &Register{RegistrationToken: token, Guardian: url, Tag: "edge", AccessToken: server.AccessToken()}
+	if _, err := second.register(second.TracerConfig()); err == nil || !strings.Contains(err.Error(), created.ConfluxID) {
+		t.Fatalf("err = %v, want conflux %s already registered", err, created.ConfluxID)
+	}
+	if got := len(server.Confluxes()); got != 1 {
+		t.Errorf("confluxes = %d, want 1", got)
+	}
+
+	// --force registers a new conflux
+	second.Force = true
+	forced, err := second.register(second.TracerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forced.ConfluxID == created.ConfluxID {
+		t.Errorf("--force reused conflux %s", created.ConfluxID)
+	}
+	if got := len(server.Confluxes()); got != 2 {
+		t.Errorf("confluxes = %d, want 2", got)
+	}
+}
+
+func TestRegisterUnknownTagRegistersNew(t *testing.T) {
+	server, url, token := testGuardian(t)
+	cmd := &Register{RegistrationToken: token, Guardian: url, Tag: "edge", AccessToken: server.AccessToken()}
+	response, err := cmd.register(cmd.TracerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	confluxes := server.Confluxes()
+	if len(confluxes) != 1 || confluxes[0].ID != response.ConfluxID || confluxes[0].Tag != "edge" {
+		t.Errorf("confluxes = %+v, want the new conflux %s tagged edge", confluxes, response.ConfluxID)
+	}
+}
+
+func TestExistingConflux(t *testing.T) {
+	server, url, token := testGuardian(t)
+	cmd := &Register{Guardian: url, Tag: "edge"}
+	if config, err := cmd.existingConflux(); config != nil || err != nil {
+		t.Fatalf("without conflux.json got %v, %v, want nothing", config, err)
+	}
+
+	registered := &Register{RegistrationToken: token, Guardian: url, Tag: "edge"}
+	response, err := registered.register(registered.TracerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &anchor.ConfluxConfig{ConfluxID: response.ConfluxID, Token: response.Token, Guardian: url, Tag: "edge"}
+	if err := anchor.SaveConfig(saved); err != nil {
+		t.Fatal(err)
+	}
+	for _, accessToken := range []string{"", server.AccessToken()} {
+		cmd := &Register{Guardian: url, Tag: "edge", AccessToken: accessToken}
+		config, err := cmd.existingConflux()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.ConfluxID != response.ConfluxID || config.Token != response.Token {
+			t.Errorf("config = %+v, want %s with its token", config, response.ConfluxID)
+		}
+	}
+
+	tests := []struct {
+		name string
+		cmd  *Register
+		want string
+	}{
+		{"another Guardian", &Register{Guardian: "https://guardian.example.com", Tag: "edge"}, "is registered with"},
+		{"another tag", &Register{Guardian: url, Tag: "core"}, "is tagged"},
+		{"invalid access token", &Register{Guardian: url, Tag: "edge", AccessToken: "invalid"}, "failed to check"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cmd.existingConflux(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A conflux the Guardian no longer lists is not reused
+	saved.ConfluxID = "unknown"
+	if err := anchor.SaveConfig(saved); err != nil {
+		t.Fatal(err)
+	}
+	cmd = &Register{Guardian: url, Tag: "edge", AccessToken: server.AccessToken()}
+	if _, err := cmd.existingConflux(); err == nil || !strings.Contains(err.Error(), "no longer registered") {
+		t.Errorf("err = %v, want the conflux no longer registered", err)
+	}
+}
+
+func TestCleanupEphemeral(t *testing.T) {
+	server, url, token := testGuardian(t)
+	cmd := &Register{Guardian: url, Ephemeral: true}
+	var records []*anchor.EphemeralRecord
+	for range 2 {
+		registered := &Register{RegistrationToken: token, Guardian: url, Ephemeral: true}
+		response, err := registered.register(registered.TracerConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := &anchor.EphemeralRecord{ConfluxID: response.ConfluxID, Guardian: url, Token: response.Token, RegisteredAt: time.Now().UTC()}
+		if err := anchor.SaveEphemeralRecord(record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	// The record of a running process is kept
+	release, err := anchor.LockEphemeral(records[0].ConfluxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.cleanupEphemeral()
+	if record, _ := anchor.LoadEphemeralRecord(records[0].ConfluxID); record == nil {
+		t.Fatal("the record of a running process was removed")
+	}
+	if record, _ := anchor.LoadEphemeralRecord(records[1].ConfluxID); record != nil {
+		t.Fatal("the record left behind was kept")
+	}
+	confluxes := server.Confluxes()
+	if len(confluxes) != 1 || confluxes[0].ID != records[0].ConfluxID {
+		t.Fatalf("confluxes = %+v, want only the running conflux %s", confluxes, records[0].ConfluxID)
+	}
+	release()
+
+	// A record of another Guardian is kept
+	other := &Register{Guardian: "https://guardian.example.com", Ephemeral: true}
+	other.cleanupEphemeral()
+	if record, _ := anchor.LoadEphemeralRecord(records[0].ConfluxID); record == nil {
+		t.Fatal("the record of another Guardian was removed")
+	}
+
+	cmd.cleanupEphemeral()
+	if got := len(server.Confluxes()); got != 0 {
+		t.Errorf("confluxes = %d, want the ephemeral conflux unregistered", got)
+	}
+	if record, err := anchor.LoadEphemeralRecord(records[0].ConfluxID); record != nil || err != nil {
+		t.Errorf("record = %v, %v, want it removed", record, err)
+	}
+
+	// A conflux the Guardian no longer knows counts as unregistered
+	if err := anchor.SaveEphemeralRecord(records[0]); err != nil {
+		t.Fatal(err)
+	}
+	cmd.cleanupEphemeral()
+	if ids, _ := anchor.EphemeralConfluxes(); len(ids) != 0 {
+		t.Errorf("records = %v, want the record of an unknown conflux removed", ids)
+	}
+}
+
+func TestRegisterEphemeralPortalRequiresRegistrationToken(t *testing.T) {
+	cmd := &Register{Ephemeral: true, Portal: true, JWT: "jwt"}
+	if err := cmd.Run(&Globals{}); err == nil || !strings.Contains(err.Error(), "--registration-token") {
+		t.Errorf("err = %v, want --registration-token required", err)
+	}
+}
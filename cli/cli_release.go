@@ -0,0 +1,7 @@
+//go:build !dev
+
+package cli
+
+// DevCommands adds no command outside dev builds, whose dev command serves the fakes of the anchortest and
+// guardiantest packages.
+type DevCommands struct{}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+
+	"github.com/alecthomas/kong"
+	"github.com/veil-net/conflux/secret"
+)
+
+// secretFileSuffix is appended to the environment variables of secret flags to name a file holding the secret,
+// e.g. VEILNET_CONFLUX_TOKEN_FILE=/run/secrets/conflux_token.
+const secretFileSuffix = "_FILE"
+
+// lookupSecretFile returns a file: reference for the first *_FILE environment variable of a secret flag that is set.
+func lookupSecretFile(flag *kong.Flag) (string, bool) {
+	if !flag.Tag.Has("secret") {
+		return "", false
+	}
+	for _, env := range flag.Envs {
+		if path, ok := os.LookupEnv(env + secretFileSuffix); ok {
+			return "file:" + path, true
+		}
+	}
+	return "", false
+}
+
+// secretFileResolver resolves secret flags from their *_FILE environment variables; the plain variable wins if both are set.
+var secretFileResolver = kong.ResolverFunc(func(_ *kong.Context, _ *kong.Path, flag *kong.Flag) (any, error) {
+	for _, env := range flag.Envs {
+		if _, ok := os.LookupEnv(env); ok {
+			return nil, nil
+		}
+	}
+	if ref, ok := lookupSecretFile(flag); ok {
+		return ref, nil
+	}
+	return nil, nil
+})
+
+// resolveSecrets replaces the file:, env: and exec: references given to the secret flags of the selected command with
+// the secrets they name.
+//
+// Inputs:
+//   - ctx: *kong.Context. The parse context after flags have been applied.
+//
+// Outputs:
+//   - err: error. Non-nil if a reference cannot be fetched.
+func resolveSecrets(ctx *kong.Context) error {
+	node := ctx.Selected()
+	if node == nil {
+		return nil
+	}
+	for _, group := range node.AllFlags(false) {
+		for _, flag := range group {
+			if !flag.Tag.Has("secret") || flag.Target.Kind() != reflect.String {
+				continue
+			}
+			value, err := secret.Resolve(context.Background(), flag.Target.String())
+			if err != nil {
+				return fmt.Errorf("--%s: %w", flag.Name, err)
+			}
+			flag.Target.SetString(value)
+		}
+	}
+	return nil
+}
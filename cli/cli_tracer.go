@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+)
+
+// tracerApplyTimeout bounds restarting the running anchor with new tracer settings.
+const tracerApplyTimeout = 30 * time.Second
+
+// TracerFlags holds the OTLP tracer flags shared by up and register.
+type TracerFlags struct {
+	Tracer         bool   `help:"Enable tracer, default: false" default:"false" env:"VEILNET_TRACER" json:"tracer"`
+	OTLPEndpoint   string `help:"The OTLP endpoint for the metrics" env:"VEILNET_OTLP_ENDPOINT" json:"otlp_endpoint"`
+	OTLPUseTLS     bool   `help:"Enable TLS for the metrics" default:"false" env:"VEILNET_OTLP_USE_TLS" json:"otlp_use_tls"`
+	OTLPInsecure   bool   `help:"Enable insecure mode for the metrics" default:"false" env:"VEILNET_OTLP_INSECURE" json:"otlp_insecure"`
+	OTLPCACert     string `help:"The OTLP CA certificate for the metrics" env:"VEILNET_OTLP_CA_CERT" json:"otlp_ca_cert"`
+	OTLPClientCert string `help:"The OTLP client certificate for the metrics" env:"VEILNET_OTLP_CLIENT_CERT" json:"otlp_client_cert"`
+	OTLPClientKey  string `help:"The OTLP client key for the metrics" env:"VEILNET_OTLP_CLIENT_KEY" json:"otlp_client_key"`
+}
+
+// TracerConfig returns the tracer config described by the flags.
+//
+// Inputs:
+//   - f: *TracerFlags. The parsed tracer flags.
+//
+// Outputs:
+//   - *anchor.TracerConfig. The tracer config.
+func (f *TracerFlags) TracerConfig() *anchor.TracerConfig {
+	return &anchor.TracerConfig{
+		Enabled:  f.Tracer,
+		Endpoint: f.OTLPEndpoint,
+		UseTLS:   f.OTLPUseTLS,
+		Insecure: f.OTLPInsecure,
+		CAFile:   f.OTLPCACert,
+		CertFile: f.OTLPClientCert,
+		KeyFile:  f.OTLPClientKey,
+	}
+}
+
+// Tracer enables, disables or updates the tracer via enable/disable/set subcommands.
+type Tracer struct {
+	Enable  TracerEnable  `cmd:"enable" help:"Enable the tracer, optionally updating its settings"`
+	Disable TracerDisable `cmd:"disable" help:"Disable the tracer"`
+	Set     TracerSet     `cmd:"set" help:"Update the tracer settings without enabling or disabling it"`
+}
+
+// TracerSettings holds the tracer settings to change; flags that are not given keep their saved value.
+type TracerSettings struct {
+	OTLPEndpoint   *string `help:"The OTLP endpoint (host:port)"`
+	OTLPUseTLS     *bool   `help:"Enable TLS for the OTLP endpoint" negatable:""`
+	OTLPInsecure   *bool   `help:"Skip verification of the OTLP server certificate" negatable:""`
+	OTLPCACert     *string `help:"The OTLP CA certificate file, empty to clear" type:"path"`
+	OTLPClientCert *string `help:"The OTLP client certificate file, empty to clear" type:"path"`
+	OTLPClientKey  *string `help:"The OTLP client key file, empty to clear" type:"path"`
+}
+
+// apply copies the given settings onto tracer.
+func (s *TracerSettings) apply(tracer *anchor.TracerConfig) {
+	if s.OTLPEndpoint != nil {
+		tracer.Endpoint = *s.OTLPEndpoint
+	}
+	if s.OTLPUseTLS != nil {
+		tracer.UseTLS = *s.OTLPUseTLS
+	}
+	if s.OTLPInsecure != nil {
+		tracer.Insecure = *s.OTLPInsecure
+	}
+	if s.OTLPCACert != nil {
+		tracer.CAFile = *s.OTLPCACert
+	}
+	if s.OTLPClientCert != nil {
+		tracer.CertFile = *s.OTLPClientCert
+	}
+	if s.OTLPClientKey != nil {
+		tracer.KeyFile = *s.OTLPClientKey
+	}
+}
+
+// TracerEnable enables the tracer.
+type TracerEnable struct {
+	TracerSettings
+}
+
+// Run enables the tracer with the saved settings updated by the given flags.
+//
+// Inputs:
+//   - cmd: *TracerEnable. The settings to change.
+//
+// Outputs:
+//   - err: error. Non-nil if the settings are invalid or cannot be saved or applied.
+func (cmd *TracerEnable) Run() error {
+	return updateTracer(func(tracer *anchor.TracerConfig) {
+		cmd.apply(tracer)
+		tracer.Enabled = true
+	})
+}
+
+// TracerDisable disables the tracer.
+type TracerDisable struct{}
+
+// Run disables the tracer, keeping its settings for a later enable.
+//
+// Inputs:
+//   - cmd: *TracerDisable. The subcommand.
+//
+// Outputs:
+//   - err: error. Non-nil if the config cannot be saved or applied.
+func (cmd *TracerDisable) Run() error {
+	return updateTracer(func(tracer *anchor.TracerConfig) {
+		tracer.Enabled = false
+	})
+}
+
+// TracerSet updates the tracer settings.
+type TracerSet struct {
+	TracerSettings
+}
+
+// Run updates the saved tracer settings with the given flags.
+//
+// Inputs:
+//   - cmd: *TracerSet. The settings to change.
+//
+// Outputs:
+//   - err: error. Non-nil if the settings are invalid or cannot be saved or applied.
+func (cmd *TracerSet) Run() error {
+	return updateTracer(cmd.apply)
+}
+
+// updateTracer changes the saved tracer config with update, validates and saves it, then restarts the running
+// anchor with it. When the anchor is not running the settings apply on its next start.
+//
+// Inputs:
+//   - update: func(*anchor.TracerConfig). Changes a copy of the saved tracer config.
+//
+// Outputs:
+//   - err: error. Non-nil if the config cannot be loaded, is invalid, or cannot be saved or applied.
+func updateTracer(update func(*anchor.TracerConfig)) error {
+	config, err := anchor.LoadConfig()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to load config: %v", err)
+		return err
+	}
+
+	// Update and validate a copy of the tracer config
+	tracer := &anchor.TracerConfig{}
+	if config.Tracer != nil {
+		*tracer = *config.Tracer
+	}
+	update(tracer)
+	if err := tracer.Validate(); err != nil {
+		Logger.Sugar().Errorf("invalid tracer config: %v", err)
+		return err
+	}
+	config.Tracer = tracer
+
+	// Save the configuration
+	if err := anchor.SaveConfig(config); err != nil {
+		Logger.Sugar().Errorf("failed to save config: %v", err)
+		return err
+	}
+
+	// Apply to the running anchor
+	client, err := anchor.NewAnchorClient()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tracerApplyTimeout)
+	defer cancel()
+	if !anchor.CheckLiveness(ctx, client).Healthy {
+		Logger.Sugar().Infof("saved tracer config, the anchor is not running so it will apply on the next start")
+		return nil
+	}
+	if err := anchor.RestartAnchor(ctx, client, config); err != nil {
+		Logger.Sugar().Errorf("failed to apply tracer config: %v", err)
+		return err
+	}
+
+	Logger.Sugar().Infof("saved tracer config and restarted the anchor with it")
+	return nil
+}
@@ -10,7 +10,7 @@ import (
 
 // Unregister unregisters the conflux and removes the service; requires the registration token.
 type Unregister struct {
-	RegistrationToken string `short:"t" help:"The registration token" env:"VEILNET_REGISTRATION_TOKEN" json:"registration_token"`
+	RegistrationToken string `short:"t" help:"The registration token, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_REGISTRATION_TOKEN" secret:"" json:"registration_token"`
 }
 
 // Run unregisters the conflux with the guardian, deletes config, and removes the service.
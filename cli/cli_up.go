@@ -5,17 +5,15 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/veil-net/conflux/anchor"
-	pb "github.com/veil-net/conflux/proto"
 	"github.com/veil-net/conflux/service"
 )
 
-// Up starts the veilnet service with a conflux token; flags include conflux ID, token, guardian, rift/portal, IP, taints, and debug.
+// Up starts the veilnet service with a conflux token; flags include conflux ID, token, guardian, rift/portal, IP, taints, tracer, debug, and HTTP monitor address.
 type Up struct {
-	ConfluxID string   `short:"i" help:"The conflux ID, please keep it secret" env:"VEILNET_CONFLUX_ID" json:"conflux_id"`
-	Token     string   `short:"t" help:"The conflux token, please keep it secret" env:"VEILNET_CONFLUX_TOKEN" json:"conflux_token"`
+	ConfluxID string   `short:"i" help:"The conflux ID, please keep it secret, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_CONFLUX_ID" secret:"" json:"conflux_id"`
+	Token     string   `short:"t" help:"The conflux token, please keep it secret, or a file:, env: or exec: reference to fetch it from" env:"VEILNET_CONFLUX_TOKEN" secret:"" json:"conflux_token"`
 	Guardian  string   `help:"The Guardian URL (Authentication Server), default: https://guardian.veilnet.app" default:"https://guardian.veilnet.app" env:"VEILNET_GUARDIAN" json:"guardian"`
 	Rift      bool     `short:"r" help:"Enable rift mode, default: false" default:"false" env:"VEILNET_CONFLUX_RIFT" json:"rift"`
 	Portal    bool     `short:"p" help:"Enable portal mode, default: false" default:"false" env:"VEILNET_CONFLUX_PORTAL" json:"portal"`
@@ -23,17 +21,26 @@ type Up struct {
 	IP        string   `help:"The IP of the conflux" env:"VEILNET_CONFLUX_IP" json:"ip"`
 	Taints    []string `help:"Taints for the conflux, conflux can only communicate with other conflux with taints that are either a super set or a subset" env:"VEILNET_CONFLUX_TAINTS" json:"taints"`
 	Debug     bool     `short:"d" help:"Enable debug mode, this will not install the service but run conflux directly" env:"VEILNET_CONFLUX_DEBUG" json:"debug"`
+	HTTPAddr  string   `help:"Listen address of the service HTTP endpoint serving /metrics, /healthz and /readyz (e.g. 127.0.0.1:9193), disabled when empty" env:"VEILNET_HTTP_ADDR" json:"http_addr"`
+
+	TracerFlags
 }
 
 // Run saves config and either installs the service or runs the anchor in debug mode.
 //
 // Inputs:
-//   - cmd: *Up. Conflux ID, token, guardian, rift/portal, IP, taints, debug.
+//   - cmd: *Up. Conflux ID, token, guardian, rift/portal, IP, taints, tracer options, debug.
+//   - globals: *Globals. Global flags; the effective logging options are saved with the config.
 //
 // Outputs:
-//   - err: error. Non-nil if config save, service install, or anchor start fails.
-func (cmd *Up) Run() error {
+//   - err: error. Non-nil if the tracer options are invalid or config save, service install, or anchor start fails.
+func (cmd *Up) Run(globals *Globals) error {
 	// Parse the config
+	tracerConfig := cmd.TracerConfig()
+	if err := tracerConfig.Validate(); err != nil {
+		Logger.Sugar().Errorf("invalid tracer config: %v", err)
+		return err
+	}
 	config := &anchor.ConfluxConfig{
 		ConfluxID: cmd.ConfluxID,
 		Token:     cmd.Token,
@@ -43,6 +50,9 @@ func (cmd *Up) Run() error {
 		Conduit:   cmd.Conduit,
 		IP:        cmd.IP,
 		Taints:    cmd.Taints,
+		Tracer:    tracerConfig,
+		Logging:   globals.Logging(),
+		HTTPAddr:  cmd.HTTPAddr,
 	}
 
 	// Save the configuration
@@ -65,56 +75,8 @@ func (cmd *Up) Run() error {
 	}
 
 
-	// Initialize the anchor plugin
-	subprocess, err := anchor.NewAnchor()
-	if err != nil {
-		Logger.Sugar().Errorf("failed to initialize anchor subprocess: %v", err)
-		return err
-	}
-
-	// Wait for the subprocess to start
-	time.Sleep(1 * time.Second)
-
-	// Create a gRPC client connection
-	anchor, err := anchor.NewAnchorClient()
-	if err != nil {
-		subprocess.Process.Kill()
-		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
-		return err
-	}
-
-	// Start the anchor
-	_, err = anchor.StartAnchor(context.Background(), &pb.StartAnchorRequest{
-		GuardianUrl: config.Guardian,
-		AnchorToken: config.Token,
-		Ip:          config.IP,
-		Rift:        config.Rift,
-		Portal:      config.Portal,
-		Conduit:     config.Conduit,
-	})
-	if err != nil {
-		subprocess.Process.Kill()
-		Logger.Sugar().Errorf("failed to start anchor: %v", err)
-		return err
-	}
-
-	// Add taints
-	for _, taint := range config.Taints {
-		_, err = anchor.AddTaint(context.Background(), &pb.AddTaintRequest{
-			Taint: taint,
-		})
-		if err != nil {
-			Logger.Sugar().Warnf("failed to add taint: %v", err)
-			continue
-		}
-	}
-
-	// Wait for interrupt signal
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
-
-	// Wait for interrupt signal
-	<-interrupt
-
-	return nil
+	// Run the anchor in the foreground until interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return service.NewServiceImpl().ServeConfig(ctx, config, nil)
 }
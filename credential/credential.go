@@ -0,0 +1,88 @@
+// Package credential keeps the conflux token out of conflux.json, in the secure store of the OS: DPAPI on Windows,
+// the System keychain on macOS and a file sealed with a machine-bound key on Linux.
+package credential
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/veil-net/conflux/logger"
+)
+
+// Logger re-exports the global logger for the credential package.
+var Logger = logger.Logger
+
+// ErrNotFound is returned by Store.Load when no token is stored for the conflux.
+var ErrNotFound = errors.New("credential not found")
+
+// ErrUnavailable is returned by Open when the OS offers no usable secure store; callers fall back to plaintext.
+var ErrUnavailable = errors.New("no secure credential store available")
+
+// Store holds the token of a conflux.
+type Store interface {
+	// Name identifies the store in conflux.json (dpapi, keychain, sealed-file).
+	Name() string
+	// Load returns the token of the conflux, or ErrNotFound.
+	Load(confluxID string) (string, error)
+	// Save stores the token of the conflux, replacing any previous one.
+	Save(confluxID, token string) error
+	// Delete removes the token of the conflux; deleting a missing token is not an error.
+	Delete(confluxID string) error
+}
+
+// Open returns the secure store of this OS.
+//
+// Inputs:
+//   - dir: string. The config directory, where file-based stores keep their file.
+//
+// Outputs:
+//   - Store. The secure store.
+//   - err: error. Wraps ErrUnavailable if the OS offers no usable secure store.
+func Open(dir string) (Store, error) {
+	return openPlatform(dir)
+}
+
+// writeFile writes data to path with owner-only permissions (those of os.CreateTemp), replacing the file atomically.
+// restrict, if not nil, further restricts access to the file before data is written, e.g. where mode bits do not apply.
+func writeFile(path string, data []byte, restrict func(path string) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if restrict != nil {
+		if err := restrict(tmp.Name()); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readFile reads a store file, mapping a missing file to ErrNotFound.
+func readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// removeFile removes a store file, ignoring a missing file.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
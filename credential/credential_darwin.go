@@ -0,0 +1,103 @@
+//go:build darwin
+// +build darwin
+
+package credential
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainService is the service name of the keychain item holding the token.
+const keychainService = "org.veilnet.conflux"
+
+// systemKeychain is the keychain of the launchd daemon, which runs as root.
+const systemKeychain = "/Library/Keychains/System.keychain"
+
+// keychainItemNotFound is the exit code of the security tool when the item does not exist.
+const keychainItemNotFound = 44
+
+// keychainStore keeps the token as a generic password in the System keychain, with the conflux ID as the account.
+type keychainStore struct{}
+
+// openPlatform returns the keychain store, unavailable without the security tool.
+func openPlatform(dir string) (Store, error) {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return keychainStore{}, nil
+}
+
+// Name returns keychain.
+func (keychainStore) Name() string {
+	return "keychain"
+}
+
+// Load reads the token of the conflux from the keychain.
+func (keychainStore) Load(confluxID string) (string, error) {
+	out, err := security("find-generic-password", "-a", confluxID, "-s", keychainService, "-w", systemKeychain)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+// Save adds or updates the keychain item of the conflux. The command is written to an interactive security session
+// on stdin, so the token never appears in an argv other local users can read with ps, and the item is read back since
+// the session does not report the exit status of its commands.
+func (s keychainStore) Save(confluxID, token string) error {
+	for _, value := range []string{confluxID, token} {
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"'\\") {
+			return errors.New("the conflux ID and token must be non-empty without whitespace, quotes or backslashes")
+		}
+	}
+	command := fmt.Sprintf("add-generic-password -U -a %s -s %s -l \"VeilNet Conflux token\" -w %s %s\n", confluxID, keychainService, token, systemKeychain)
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("security add-generic-password: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("security add-generic-password: %s", msg)
+	}
+
+	// Confirm the item holds the token
+	saved, err := s.Load(confluxID)
+	if err != nil {
+		return err
+	}
+	if saved != token {
+		return errors.New("security add-generic-password: the keychain item does not hold the token")
+	}
+	return nil
+}
+
+// Delete removes the keychain item of the conflux.
+func (keychainStore) Delete(confluxID string) error {
+	_, err := security("delete-generic-password", "-a", confluxID, "-s", keychainService, systemKeychain)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// security runs the security tool, mapping a missing item to ErrNotFound.
+func security(args ...string) (string, error) {
+	out, err := exec.Command("security", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if exitErr.ExitCode() == keychainItemNotFound {
+				return "", ErrNotFound
+			}
+			return "", fmt.Errorf("security %s: %w: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(out), nil
+}
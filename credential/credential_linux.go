@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package credential
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sealedFileName is the file holding the sealed token in the config directory.
+const sealedFileName = "conflux.token.sealed"
+
+// machineIDPaths are read in order for the machine ID the sealing key is derived from.
+var machineIDPaths = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// sealedStore seals the token with AES-GCM under a key derived from the machine ID, with the conflux ID as associated
+// data. The file is readable by root only; sealing binds it to the host, so a copied config directory or backup does
+// not disclose the token elsewhere.
+type sealedStore struct {
+	path string
+	key  [32]byte
+}
+
+// openPlatform returns the sealed file store, unavailable when the host has no machine ID (e.g. minimal containers).
+func openPlatform(dir string) (Store, error) {
+	machineID := ""
+	for _, path := range machineIDPaths {
+		if data, err := os.ReadFile(path); err == nil {
+			if machineID = strings.TrimSpace(string(data)); machineID != "" {
+				break
+			}
+		}
+	}
+	if machineID == "" {
+		return nil, fmt.Errorf("%w: no machine ID in %s", ErrUnavailable, strings.Join(machineIDPaths, " or "))
+	}
+	return &sealedStore{
+		path: filepath.Join(dir, sealedFileName),
+		key:  sha256.Sum256([]byte("veilnet-conflux credential\x00" + machineID)),
+	}, nil
+}
+
+// Name returns sealed-file.
+func (s *sealedStore) Name() string {
+	return "sealed-file"
+}
+
+// aead returns the AES-GCM cipher of the store key.
+func (s *sealedStore) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Load unseals the token of the conflux.
+func (s *sealedStore) Load(confluxID string) (string, error) {
+	data, err := readFile(s.path)
+	if err != nil {
+		return "", err
+	}
+	aead, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("sealed token is truncated")
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	token, err := aead.Open(nil, nonce, sealed, []byte(confluxID))
+	if err != nil {
+		return "", fmt.Errorf("failed to unseal %s, the machine ID or the conflux ID changed: %w", s.path, err)
+	}
+	return string(token), nil
+}
+
+// Save seals the token of the conflux.
+func (s *sealedStore) Save(confluxID, token string) error {
+	aead, err := s.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return writeFile(s.path, aead.Seal(nonce, nonce, []byte(token), []byte(confluxID)), nil)
+}
+
+// Delete removes the sealed file.
+func (s *sealedStore) Delete(confluxID string) error {
+	return removeFile(s.path)
+}
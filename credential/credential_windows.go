@@ -0,0 +1,88 @@
+//go:build windows
+// +build windows
+
+package credential
+
+import (
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// dpapiFileName is the file holding the DPAPI-protected token in the config directory.
+const dpapiFileName = "conflux.token.dpapi"
+
+// dpapiFileSDDL is the protected DACL of the token file: full access for SYSTEM and the Administrators group only.
+const dpapiFileSDDL = "D:P(A;;FA;;;SY)(A;;FA;;;BA)"
+
+// dpapiStore protects the token with DPAPI in the machine scope, so both the service (LocalSystem) and an elevated
+// CLI can unprotect it, with the conflux ID as additional entropy. Any account of the machine could unprotect the
+// blob, so the file is only readable by SYSTEM and Administrators.
+type dpapiStore struct {
+	path string
+}
+
+// openPlatform returns the DPAPI store, which is always available on Windows.
+func openPlatform(dir string) (Store, error) {
+	return &dpapiStore{path: filepath.Join(dir, dpapiFileName)}, nil
+}
+
+// Name returns dpapi.
+func (s *dpapiStore) Name() string {
+	return "dpapi"
+}
+
+// Load unprotects the token of the conflux.
+func (s *dpapiStore) Load(confluxID string) (string, error) {
+	data, err := readFile(s.path)
+	if err != nil {
+		return "", err
+	}
+	var out windows.DataBlob
+	err = windows.CryptUnprotectData(blob(data), nil, blob([]byte(confluxID)), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	if err != nil {
+		return "", err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return string(unsafe.Slice(out.Data, out.Size)), nil
+}
+
+// Save protects the token of the conflux.
+func (s *dpapiStore) Save(confluxID, token string) error {
+	var out windows.DataBlob
+	flags := uint32(windows.CRYPTPROTECT_UI_FORBIDDEN | windows.CRYPTPROTECT_LOCAL_MACHINE)
+	err := windows.CryptProtectData(blob([]byte(token)), nil, blob([]byte(confluxID)), 0, nil, flags, &out)
+	if err != nil {
+		return err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return writeFile(s.path, append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), restrictToAdministrators)
+}
+
+// restrictToAdministrators replaces the inherited DACL of the file with dpapiFileSDDL.
+func restrictToAdministrators(path string) error {
+	sd, err := windows.SecurityDescriptorFromString(dpapiFileSDDL)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	info := windows.SECURITY_INFORMATION(windows.DACL_SECURITY_INFORMATION | windows.PROTECTED_DACL_SECURITY_INFORMATION)
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, info, nil, nil, dacl, nil)
+}
+
+// Delete removes the protected file.
+func (s *dpapiStore) Delete(confluxID string) error {
+	return removeFile(s.path)
+}
+
+// blob wraps data in a DPAPI blob.
+func blob(data []byte) *windows.DataBlob {
+	if len(data) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
@@ -0,0 +1,201 @@
+// Package doctor diagnoses the host environment the conflux runs in and suggests remediations.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/guardian"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+// Check outcomes; only StatusFail makes the environment unhealthy.
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Result is the outcome of a single check with a remediation hint for warnings and failures.
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint"`
+}
+
+// Options holds the settings of a doctor run.
+type Options struct {
+	// Guardian is the Guardian URL to probe; empty uses the one from conflux.json or anchor.DefaultGuardian.
+	Guardian string
+	// Timeout bounds each network check.
+	Timeout time.Duration
+}
+
+// maxClockSkew is the clock difference with the Guardian above which signed headers may be rejected.
+const maxClockSkew = 30 * time.Second
+
+// check is a single diagnostic.
+type check func(ctx context.Context, options Options) Result
+
+// Run runs the platform checks followed by the common ones (port, config, Guardian, clock).
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the checks.
+//   - options: Options. The Guardian URL and the network timeout.
+//
+// Outputs:
+//   - []Result. One result per check, in order.
+func Run(ctx context.Context, options Options) []Result {
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+	if options.Guardian == "" {
+		options.Guardian = anchor.DefaultGuardian
+		if config, err := anchor.LoadConfig(); err == nil && config.Guardian != "" {
+			options.Guardian = config.Guardian
+		}
+	}
+
+	checks := append(platformChecks(), checkAnchorPort, checkConfig, checkGuardian)
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		results = append(results, check(ctx, options))
+	}
+	return results
+}
+
+// Failed returns the results with StatusFail.
+//
+// Inputs:
+//   - results: []Result. The results of Run.
+//
+// Outputs:
+//   - []Result. The failed results.
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, result := range results {
+		if result.Status == StatusFail {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// checkAnchorPort checks that the anchor gRPC port is free, or held by a running anchor.
+func checkAnchorPort(ctx context.Context, options Options) Result {
+	result := Result{Check: "anchor port"}
+	listener, err := net.Listen("tcp", anchor.Address())
+	if err == nil {
+		listener.Close()
+		result.Status = StatusOK
+		result.Detail = anchor.Address() + " is free"
+		return result
+	}
+
+	// The port is taken; fine if the anchor itself answers on it
+	client, clientErr := anchor.NewAnchorClient()
+	if clientErr == nil {
+		ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+		if anchor.CheckLiveness(ctx, client).Healthy {
+			result.Status = StatusOK
+			result.Detail = anchor.Address() + " is used by the running anchor"
+			return result
+		}
+	}
+	result.Status = StatusFail
+	result.Detail = fmt.Sprintf("%s is in use by another process: %v", anchor.Address(), err)
+	result.Hint = "stop the process listening on port 1993, or the stale conflux/anchor process"
+	return result
+}
+
+// checkConfig checks that conflux.json is readable and valid.
+func checkConfig(ctx context.Context, options Options) Result {
+	result := Result{Check: "config"}
+	configDir, _ := anchor.GetConfigDir()
+	path := filepath.Join(configDir, "conflux.json")
+	config, err := anchor.LoadConfig()
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		result.Status = StatusWarn
+		result.Detail = path + " does not exist"
+		result.Hint = "register the conflux with `conflux register` or `conflux up`"
+	case errors.Is(err, fs.ErrPermission):
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("%s is not readable: %v", path, err)
+		result.Hint = "run the command as root or Administrator"
+	case err != nil:
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("%s is invalid: %v", path, err)
+		result.Hint = "fix the JSON syntax or register the conflux again"
+	case config.ConfluxID == "" || config.Token == "":
+		result.Status = StatusFail
+		result.Detail = path + " has no conflux ID or token"
+		result.Hint = "register the conflux again with `conflux register`"
+	default:
+		result.Status = StatusOK
+		result.Detail = fmt.Sprintf("%s is valid (conflux %s)", path, config.ConfluxID)
+	}
+	return result
+}
+
+// checkGuardian checks that the Guardian answers its health endpoint and that the local clock agrees with its Date header.
+func checkGuardian(ctx context.Context, options Options) Result {
+	result := Result{Check: "guardian"}
+	ctx, cancel := context.WithTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(options.Guardian, "/") + "/health"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("invalid Guardian URL %q: %v", options.Guardian, err)
+		result.Hint = "set a valid URL with --guardian"
+		return result
+	}
+	sent := time.Now()
+	resp, err := guardian.Default().Do(req)
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("%s is unreachable: %v", options.Guardian, err)
+		result.Hint = "check DNS, the firewall and the proxy (--guardian-proxy, HTTPS_PROXY); a self-hosted Guardian may need --guardian-ca-cert"
+		return result
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("%s answered %s", url, resp.Status)
+		result.Hint = "the Guardian may be down, retry later or check the Guardian URL"
+		return result
+	}
+
+	// Compare the clocks; the Date header has a one second resolution
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		result.Status = StatusWarn
+		result.Detail = options.Guardian + " is reachable but sent no Date header, clock skew not checked"
+		return result
+	}
+	skew := sent.Add(time.Since(sent) / 2).Sub(date).Truncate(time.Second)
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("%s is reachable but the local clock is off by %s", options.Guardian, skew)
+		result.Hint = "synchronise the clock with NTP (e.g. timedatectl set-ntp true), signed requests depend on it"
+		return result
+	}
+	result.Status = StatusOK
+	result.Detail = fmt.Sprintf("%s is reachable, clock skew %s", options.Guardian, skew)
+	return result
+}
@@ -0,0 +1,51 @@
+//go:build darwin
+// +build darwin
+
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// platformChecks returns the macOS checks: root privileges and the tools the anchor runs.
+func platformChecks() []check {
+	return []check{
+		checkRoot,
+		checkTool("route", "the anchor installs the routes of the utun interface with it"),
+		checkTool("networksetup", "the anchor sets the DNS of the utun interface with it"),
+	}
+}
+
+// checkRoot checks that the process runs as root, which creating a utun interface requires.
+func checkRoot(ctx context.Context, options Options) Result {
+	result := Result{Check: "root"}
+	if os.Geteuid() != 0 {
+		result.Status = StatusFail
+		result.Detail = "the process is not running as root, utun interfaces cannot be created"
+		result.Hint = "run the command with sudo"
+		return result
+	}
+	result.Status = StatusOK
+	result.Detail = "the process is running as root"
+	return result
+}
+
+// checkTool returns a check that the command name is on the PATH; these tools ship with macOS.
+func checkTool(name, purpose string) check {
+	return func(ctx context.Context, options Options) Result {
+		result := Result{Check: name}
+		path, err := exec.LookPath(name)
+		if err != nil {
+			result.Status = StatusFail
+			result.Detail = fmt.Sprintf("%s is not found, %s", name, purpose)
+			result.Hint = "add /sbin and /usr/sbin to the PATH"
+			return result
+		}
+		result.Status = StatusOK
+		result.Detail = path
+		return result
+	}
+}
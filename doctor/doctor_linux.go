@@ -0,0 +1,113 @@
+//go:build linux
+// +build linux
+
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// capNetAdmin is the bit of CAP_NET_ADMIN in the capability sets of /proc/self/status.
+const capNetAdmin = 12
+
+// platformChecks returns the Linux checks: TUN device, CAP_NET_ADMIN and the tools the anchor runs.
+func platformChecks() []check {
+	return []check{
+		checkTUN,
+		checkNetAdmin,
+		checkTool("resolvectl", "the anchor sets the DNS of the TUN interface with it",
+			"install systemd-resolved (e.g. apt-get install systemd-resolved) and make sure it is running"),
+		checkTool("iptables", "the anchor installs its forwarding and NAT rules with it",
+			"install iptables (e.g. apt-get install iptables)"),
+		checkTool("ip", "the anchor configures the TUN interface with it",
+			"install iproute2 (e.g. apt-get install iproute2)"),
+	}
+}
+
+// checkTUN checks that /dev/net/tun exists and can be opened.
+func checkTUN(ctx context.Context, options Options) Result {
+	result := Result{Check: "tun device"}
+	info, err := os.Stat("/dev/net/tun")
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("/dev/net/tun is missing: %v", err)
+		result.Hint = "load the tun module (modprobe tun), or pass --device /dev/net/tun:/dev/net/tun to the container"
+		return result
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		result.Status = StatusFail
+		result.Detail = "/dev/net/tun is not a character device"
+		result.Hint = "recreate it with mknod /dev/net/tun c 10 200"
+		return result
+	}
+	file, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("/dev/net/tun cannot be opened: %v", err)
+		result.Hint = "run as root, and allow the device in the container (e.g. --device /dev/net/tun)"
+		return result
+	}
+	file.Close()
+	result.Status = StatusOK
+	result.Detail = "/dev/net/tun is available"
+	return result
+}
+
+// checkNetAdmin checks that the process has CAP_NET_ADMIN in its effective capability set.
+func checkNetAdmin(ctx context.Context, options Options) Result {
+	result := Result{Check: "cap_net_admin"}
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		result.Status = StatusSkip
+		result.Detail = fmt.Sprintf("cannot read /proc/self/status: %v", err)
+		return result
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !ok {
+			continue
+		}
+		caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			break
+		}
+		if caps&(1<<capNetAdmin) == 0 {
+			result.Status = StatusFail
+			result.Detail = "the process does not have CAP_NET_ADMIN"
+			result.Hint = "run as root, or add the capability (e.g. --cap-add NET_ADMIN for containers)"
+			return result
+		}
+		result.Status = StatusOK
+		result.Detail = "the process has CAP_NET_ADMIN"
+		return result
+	}
+	result.Status = StatusSkip
+	result.Detail = "no effective capability set found in /proc/self/status"
+	return result
+}
+
+// checkTool returns a check that the command name is on the PATH.
+func checkTool(name, purpose, hint string) check {
+	return func(ctx context.Context, options Options) Result {
+		result := Result{Check: name}
+		path, err := exec.LookPath(name)
+		if err != nil {
+			result.Status = StatusFail
+			result.Detail = fmt.Sprintf("%s is not installed, %s", name, purpose)
+			result.Hint = hint
+			return result
+		}
+		result.Status = StatusOK
+		result.Detail = path
+		return result
+	}
+}
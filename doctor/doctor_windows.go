@@ -0,0 +1,50 @@
+//go:build windows
+// +build windows
+
+package doctor
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// platformChecks returns the Windows checks: elevation and access to the service manager.
+func platformChecks() []check {
+	return []check{
+		checkElevated,
+		checkServiceManager,
+	}
+}
+
+// checkElevated checks that the process runs elevated, which creating the TUN adapter requires.
+func checkElevated(ctx context.Context, options Options) Result {
+	result := Result{Check: "administrator"}
+	if !windows.GetCurrentProcessToken().IsElevated() {
+		result.Status = StatusFail
+		result.Detail = "the process is not running as Administrator, the TUN adapter cannot be created"
+		result.Hint = "run the command from an elevated prompt (Run as administrator)"
+		return result
+	}
+	result.Status = StatusOK
+	result.Detail = "the process is running as Administrator"
+	return result
+}
+
+// checkServiceManager checks that the service control manager can be reached to install the service.
+func checkServiceManager(ctx context.Context, options Options) Result {
+	result := Result{Check: "service manager"}
+	m, err := mgr.Connect()
+	if err != nil {
+		result.Status = StatusFail
+		result.Detail = fmt.Sprintf("cannot connect to the service manager: %v", err)
+		result.Hint = "run the command from an elevated prompt (Run as administrator)"
+		return result
+	}
+	m.Disconnect()
+	result.Status = StatusOK
+	result.Detail = "the service manager is reachable"
+	return result
+}
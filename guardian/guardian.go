@@ -0,0 +1,323 @@
+// Package guardian provides the HTTP client every Guardian call goes through: per-attempt timeout, retries with
+// jittered backoff for safe requests, proxy, private CA, mTLS client certificate and a versioned user agent.
+package guardian
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/veil-net/conflux/buildinfo"
+	"github.com/veil-net/conflux/logger"
+)
+
+// Logger re-exports the global logger for the guardian package.
+var Logger = logger.Logger
+
+// maxBackoff caps the delay between two attempts, including delays asked for by Retry-After.
+const maxBackoff = 30 * time.Second
+
+// Options holds the transport settings of the Guardian client.
+type Options struct {
+	// Timeout bounds each attempt, from dialing to reading the response body.
+	Timeout time.Duration
+	// Retries is the number of retries after a connection error, 429 or 5xx; only safe requests are retried.
+	Retries int
+	// Backoff is the base delay before the first retry, doubled on each following one and jittered.
+	Backoff time.Duration
+	// Proxy is the proxy URL; empty uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+	Proxy string
+	// CAFile is a PEM bundle trusted instead of the system roots, for a self-hosted Guardian with a private CA.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key presented for mTLS.
+	CertFile string
+	KeyFile  string
+}
+
+// DefaultOptions returns the settings used until Configure is called.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - Options. A 30s timeout, 3 retries from a 500ms backoff, the proxy from the environment and the system roots.
+func DefaultOptions() Options {
+	return Options{
+		Timeout: 30 * time.Second,
+		Retries: 3,
+		Backoff: 500 * time.Millisecond,
+	}
+}
+
+// Client sends requests to the Guardian.
+type Client struct {
+	http    *http.Client
+	options Options
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultClient *Client
+)
+
+// Configure replaces the client returned by Default.
+//
+// Inputs:
+//   - options: Options. The transport settings.
+//
+// Outputs:
+//   - err: error. Non-nil if the proxy URL, CA bundle or client certificate is invalid.
+func Configure(options Options) error {
+	client, err := NewClient(options)
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultClient = client
+	return nil
+}
+
+// Default returns the client set by Configure, or one with DefaultOptions.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *Client. The shared Guardian client.
+func Default() *Client {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultClient == nil {
+		defaultClient, _ = NewClient(DefaultOptions())
+	}
+	return defaultClient
+}
+
+// NewClient returns a client with the given transport settings.
+//
+// Inputs:
+//   - options: Options. The transport settings; a zero timeout or backoff takes the default.
+//
+// Outputs:
+//   - *Client. The client.
+//   - err: error. Non-nil if the proxy URL, CA bundle or client certificate is invalid.
+func NewClient(options Options) (*Client, error) {
+	defaults := DefaultOptions()
+	if options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaults.Backoff
+	}
+	if options.Retries < 0 {
+		options.Retries = 0
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.Proxy != "" {
+		proxy, err := url.Parse(options.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid Guardian proxy URL %q", options.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	tlsConfig, err := options.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &Client{
+		http:    &http.Client{Transport: transport, Timeout: options.Timeout},
+		options: options,
+	}, nil
+}
+
+// tlsConfig returns the TLS settings with the CA bundle and client certificate loaded.
+func (options Options) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if options.CAFile != "" {
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Guardian CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Guardian CA file %s", options.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if options.CertFile != "" || options.KeyFile != "" {
+		if options.CertFile == "" || options.KeyFile == "" {
+			return nil, errors.New("Guardian client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Guardian client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// UserAgent returns the user agent sent to the Guardian, e.g. veilnet-conflux/Beta-v1.0.11 (linux/amd64).
+//
+// Inputs: none.
+//
+// Outputs:
+//   - string. The user agent.
+func UserAgent() string {
+	return fmt.Sprintf("veilnet-conflux/%s (%s/%s)", buildinfo.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// retryPolicy selects which failed attempts of a request are sent again.
+type retryPolicy int
+
+const (
+	// retryNever sends the request once.
+	retryNever retryPolicy = iota
+	// retryUnsent retries the attempts that failed to dial, which provably never reached the Guardian.
+	retryUnsent
+	// retryTransient retries connection errors, 429 and 5xx.
+	retryTransient
+)
+
+// Do sends the request, retrying connection errors, 429 and 5xx with jittered exponential backoff when the method is
+// safe (GET, HEAD, OPTIONS); other requests are sent once.
+//
+// Inputs:
+//   - req: *http.Request. The request; its context bounds all attempts and backoff delays.
+//
+// Outputs:
+//   - *http.Response. The response of the last attempt; the caller closes its body.
+//   - err: error. Non-nil if the last attempt failed or the context ended while waiting.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	policy := retryNever
+	if safe(req) {
+		policy = retryTransient
+	}
+	return c.do(req, policy)
+}
+
+// DoRetryUnsent sends a request with side effects, retrying with jittered exponential backoff only the attempts that
+// failed to dial, so a request the Guardian may have processed is never sent twice. The body must be replayable
+// (http.NewRequest sets GetBody for in-memory bodies).
+//
+// Inputs:
+//   - req: *http.Request. The request; its context bounds all attempts and backoff delays.
+//
+// Outputs:
+//   - *http.Response. The response of the last attempt; the caller closes its body.
+//   - err: error. Non-nil if the last attempt failed or the context ended while waiting.
+func (c *Client) DoRetryUnsent(req *http.Request) (*http.Response, error) {
+	return c.do(req, retryUnsent)
+}
+
+// do implements Do and DoRetryUnsent.
+func (c *Client) do(req *http.Request, policy retryPolicy) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		policy = retryNever
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+		resp, err := c.http.Do(attemptReq)
+		if attempt >= c.options.Retries || !shouldRetry(policy, req, resp, err) {
+			return resp, err
+		}
+
+		// Drain the failed response so its connection is reused
+		delay := c.backoff(attempt, resp)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		Logger.Sugar().Warnf("guardian request %s %s failed (%s), retrying in %s", req.Method, req.URL.Host+req.URL.Path, reason, delay.Truncate(time.Millisecond))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// safe reports whether the method of the request has no side effects, so the request can be sent again.
+func safe(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// shouldRetry reports whether an attempt failed in a way the policy retries.
+func shouldRetry(policy retryPolicy, req *http.Request, resp *http.Response, err error) bool {
+	if policy == retryNever || req.Context().Err() != nil {
+		return false
+	}
+	if policy == retryUnsent {
+		return dialFailed(err)
+	}
+	if err != nil {
+		// A certificate the client rejects will be rejected again
+		var verifyErr *tls.CertificateVerificationError
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostnameErr x509.HostnameError
+		return !errors.As(err, &verifyErr) && !errors.As(err, &unknownAuthority) && !errors.As(err, &hostnameErr)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// dialFailed reports whether the error is a failure to connect (including resolving the host), before any byte of
+// the request was sent.
+func dialFailed(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// backoff returns the delay before the retry following attempt: Retry-After if the Guardian sent one, otherwise
+// the base backoff doubled per attempt with equal jitter, capped at maxBackoff.
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxBackoff)
+		}
+	}
+	delay := min(c.options.Backoff<<attempt, maxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,203 @@
+package guardian
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a client retrying twice with a short backoff.
+func testClient(t *testing.T) *Client {
+	t.Helper()
+	client, err := NewClient(Options{Timeout: 5 * time.Second, Retries: 2, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// countingServer answers every request with status and counts the attempts.
+func countingServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &attempts
+}
+
+func TestDoRetryClassification(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		attempts int32
+	}{
+		{"GET 503 is retried", http.MethodGet, http.StatusServiceUnavailable, 3},
+		{"GET 429 is retried", http.MethodGet, http.StatusTooManyRequests, 3},
+		{"HEAD 502 is retried", http.MethodHead, http.StatusBadGateway, 3},
+		{"GET 404 is not retried", http.MethodGet, http.StatusNotFound, 1},
+		{"GET 501 is not retried", http.MethodGet, http.StatusNotImplemented, 1},
+		{"POST 500 is not retried", http.MethodPost, http.StatusInternalServerError, 1},
+		{"DELETE 503 is not retried", http.MethodDelete, http.StatusServiceUnavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, attempts := countingServer(t, tt.status)
+			req, err := http.NewRequest(tt.method, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := testClient(t).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := attempts.Load(); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestDoRetryUnsentDoesNotRetryResponses(t *testing.T) {
+	server, attempts := countingServer(t, http.StatusServiceUnavailable)
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := testClient(t).DoRetryUnsent(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
+
+// refusedClient returns a test client whose dials all fail, and the count of dials attempted.
+func refusedClient(t *testing.T) (*Client, string, *atomic.Int32) {
+	t.Helper()
+	// Reserve a port, then close the listener so every dial to it is refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	var dials atomic.Int32
+	client := testClient(t)
+	transport := client.http.Transport.(*http.Transport)
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return dial(ctx, network, addr)
+	}
+	return client, "http://" + addr, &dials
+}
+
+func TestDoRetryUnsentRetriesDialErrors(t *testing.T) {
+	client, url, dials := refusedClient(t)
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DoRetryUnsent(req); err == nil {
+		t.Fatal("expected a dial error")
+	}
+	if got := dials.Load(); got != 3 {
+		t.Errorf("dials = %d, want 3", got)
+	}
+}
+
+func TestDoDoesNotRetryUnsafeDialErrors(t *testing.T) {
+	client, url, dials := refusedClient(t)
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected a dial error")
+	}
+	if got := dials.Load(); got != 1 {
+		t.Errorf("dials = %d, want 1", got)
+	}
+}
+
+func TestDoDoesNotRetryUnreplayableBody(t *testing.T) {
+	server, attempts := countingServer(t, http.StatusServiceUnavailable)
+	req, err := http.NewRequest(http.MethodGet, server.URL, io.NopCloser(strings.NewReader("body")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetBody != nil {
+		t.Fatal("GetBody set for an opaque body")
+	}
+	resp, err := testClient(t).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	client := testClient(t)
+	client.options.Backoff = 100 * time.Millisecond
+	for attempt := range 4 {
+		delay := client.backoff(attempt, nil)
+		base := 100 * time.Millisecond << attempt
+		if delay < base/2 || delay > base {
+			t.Errorf("attempt %d: delay %s outside [%s, %s]", attempt, delay, base/2, base)
+		}
+	}
+	if delay := client.backoff(20, nil); delay > maxBackoff {
+		t.Errorf("delay %s above the cap %s", delay, maxBackoff)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+	if delay := client.backoff(0, resp); delay != 2*time.Second {
+		t.Errorf("Retry-After delay = %s, want 2s", delay)
+	}
+	resp.Header.Set("Retry-After", "3600")
+	if delay := client.backoff(0, resp); delay != maxBackoff {
+		t.Errorf("Retry-After delay = %s, want %s", delay, maxBackoff)
+	}
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	var userAgent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.UserAgent())
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := testClient(t).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := userAgent.Load(); got != UserAgent() {
+		t.Errorf("user agent = %v, want %s", got, UserAgent())
+	}
+}
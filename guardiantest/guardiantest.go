@@ -0,0 +1,471 @@
+// Package guardiantest provides a local Guardian stand-in: the guardian-api.json endpoints used by the conflux served
+// from an in-memory store, so registration, unregistration and lookups run end to end without guardian.veilnet.app.
+//
+// It mints registration tokens, registers, lists and unregisters confluxes, serves the networks set with
+// SetLocalNetworks and SetRemoteNetworks, and answers invalid requests with the documented HTTPValidationError.
+// Identity tokens sent at registration are accepted without verification.
+package guardiantest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default credentials of the user of the stand-in.
+const (
+	DefaultAccessToken = "guardiantest"
+	DefaultEmail       = "dev@veilnet.local"
+	DefaultPassword    = "guardiantest"
+	DefaultSubnet      = "10.128.0.0/16"
+)
+
+// ErrRealmNotFound is returned when minting a registration token for an unknown realm.
+var ErrRealmNotFound = errors.New("realm not found")
+
+// ErrConfluxNotFound is returned when setting the networks of an unknown conflux.
+var ErrConfluxNotFound = errors.New("conflux not found")
+
+// ErrIPNotAvailable is returned when registering a conflux with a CIDR another conflux of the realm holds, or when
+// the realm has no free address left.
+var ErrIPNotAvailable = errors.New("IP not available")
+
+// Options holds the settings of the stand-in.
+type Options struct {
+	// AccessToken is the bearer of the user for the OAuth2 endpoints, e.g. --access-token of register; default
+	// DefaultAccessToken.
+	AccessToken string
+	// Email and Password log the user in with POST /auth/login; default DefaultEmail and DefaultPassword.
+	Email    string
+	Password string
+	// Subnet is the subnet of the default realm; default DefaultSubnet.
+	Subnet string
+}
+
+// Realm is a realm as returned by the Guardian.
+type Realm struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    string    `json:"user_id"`
+	VeilID    string    `json:"veil_id"`
+	Name      string    `json:"name"`
+	Subnet    string    `json:"subnet"`
+	Public    bool      `json:"public"`
+	Region    string    `json:"region"`
+	VeilHost  string    `json:"veil_host"`
+	VeilPort  int       `json:"veil_port"`
+	Portals   int       `json:"portals"`
+	Status    string    `json:"status"`
+}
+
+// Conflux is a conflux as returned by the Guardian.
+type Conflux struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastSeen  *time.Time `json:"last_seen"`
+	UserID    string     `json:"user_id"`
+	Tag       string     `json:"tag"`
+	Signature string     `json:"signature"`
+	IPLeaseID string     `json:"ip_lease_id"`
+	CIDR      string     `json:"cidr"`
+	Subnet    string     `json:"subnet"`
+	Plane     string     `json:"plane"`
+	PlaneID   string     `json:"plane_id"`
+	Portal    bool       `json:"portal"`
+	Public    bool       `json:"public"`
+	CAPEM     string     `json:"ca_pem"`
+	KeyPEM    string     `json:"key_pem"`
+	CertPEM   string     `json:"cert_pem"`
+	VeilHost  string     `json:"veil_host"`
+	VeilPort  int        `json:"veil_port"`
+	Region    string     `json:"region"`
+}
+
+// RegistrationTokenInfo is a registration token as listed by the Guardian, without the token itself.
+type RegistrationTokenInfo struct {
+	CreatedAt time.Time `json:"created_at"`
+	UserID    string    `json:"user_id"`
+	RealmID   string    `json:"realm_id"`
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+	TokenID   string    `json:"token_id"`
+	Tag       string    `json:"tag"`
+}
+
+// LocalNetwork is a subnet a conflux advertises.
+type LocalNetwork struct {
+	Subnet string `json:"subnet"`
+}
+
+// RemoteNetwork is a subnet a conflux reaches through the peer with the signature.
+type RemoteNetwork struct {
+	PeerSignature string `json:"peer_signature"`
+	Subnet        string `json:"subnet"`
+}
+
+// ValidationError is an entry of HTTPValidationError: the location of the invalid input, e.g. ["body", "realm_id"].
+type ValidationError struct {
+	Loc  []any  `json:"loc"`
+	Msg  string `json:"msg"`
+	Type string `json:"type"`
+}
+
+// HTTPValidationError is the 422 response body of the Guardian.
+type HTTPValidationError struct {
+	Detail []ValidationError `json:"detail"`
+}
+
+// registration is the outcome of a registration.
+type registration struct {
+	ConfluxID string `json:"conflux_id"`
+	Token     string `json:"token"`
+}
+
+// Server is the Guardian stand-in.
+type Server struct {
+	options Options
+	userID  string
+
+	mu                 sync.Mutex
+	realms             []*Realm
+	registrationTokens map[string]*RegistrationTokenInfo // by token
+	confluxes          []*Conflux
+	confluxTokens      map[string]string          // conflux ID by conflux token
+	localNetworks      map[string][]LocalNetwork  // by conflux ID
+	remoteNetworks     map[string][]RemoteNetwork // by conflux ID
+	http               *http.Server
+}
+
+// NewServer creates a stand-in with one user owning one realm.
+//
+// Inputs:
+//   - options: Options. The user credentials and the realm subnet; zero values select the defaults.
+//
+// Outputs:
+//   - *Server. The stand-in, not yet serving.
+//   - err: error. Non-nil if the subnet is invalid.
+func NewServer(options Options) (*Server, error) {
+	if options.AccessToken == "" {
+		options.AccessToken = DefaultAccessToken
+	}
+	if options.Email == "" {
+		options.Email = DefaultEmail
+	}
+	if options.Password == "" {
+		options.Password = DefaultPassword
+	}
+	if options.Subnet == "" {
+		options.Subnet = DefaultSubnet
+	}
+	subnet, err := netip.ParsePrefix(options.Subnet)
+	if err != nil || !subnet.Addr().Is4() {
+		return nil, fmt.Errorf("invalid realm subnet %q, expected an IPv4 CIDR", options.Subnet)
+	}
+	s := &Server{
+		options:            options,
+		userID:             newID(),
+		registrationTokens: map[string]*RegistrationTokenInfo{},
+		confluxTokens:      map[string]string{},
+		localNetworks:      map[string][]LocalNetwork{},
+		remoteNetworks:     map[string][]RemoteNetwork{},
+	}
+	s.realms = append(s.realms, &Realm{
+		ID:        newID(),
+		CreatedAt: time.Now().UTC(),
+		UserID:    s.userID,
+		VeilID:    newID(),
+		Name:      "guardiantest",
+		Subnet:    subnet.Masked().String(),
+		Region:    "local",
+		VeilHost:  "127.0.0.1",
+		VeilPort:  443,
+		Status:    "active",
+	})
+	return s, nil
+}
+
+// Start serves the stand-in on address in the background.
+//
+// Inputs:
+//   - address: string. The host:port to listen on, port 0 for any free port.
+//
+// Outputs:
+//   - string. The URL of the stand-in, to pass as the Guardian URL.
+//   - err: error. Non-nil if the address cannot be listened on.
+func (s *Server) Start(address string) (string, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	s.mu.Lock()
+	s.http = server
+	s.mu.Unlock()
+	go server.Serve(listener)
+	return "http://" + listener.Addr().String(), nil
+}
+
+// Stop stops serving and closes open connections.
+//
+// Inputs: none.
+//
+// Outputs: none.
+func (s *Server) Stop() {
+	s.mu.Lock()
+	server := s.http
+	s.http = nil
+	s.mu.Unlock()
+	if server != nil {
+		server.Close()
+	}
+}
+
+// AccessToken returns the bearer of the user for the OAuth2 endpoints.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - string. The access token.
+func (s *Server) AccessToken() string {
+	return s.options.AccessToken
+}
+
+// Realm returns the default realm.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - Realm. A copy of the realm.
+func (s *Server) Realm() Realm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.realms[0]
+}
+
+// CreateRegistrationToken mints a registration token like POST /auth/create/registration-token.
+//
+// Inputs:
+//   - realmID: string. The realm confluxes registered with the token join.
+//   - tag: string. The tag of confluxes registered without one, may be empty.
+//   - ttl: time.Duration. How long the token is valid.
+//
+// Outputs:
+//   - string. The token ID.
+//   - string. The registration token.
+//   - err: error. Wraps ErrRealmNotFound if the realm does not exist.
+func (s *Server) CreateRegistrationToken(realmID string, tag string, ttl time.Duration) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.realm(realmID) == nil {
+		return "", "", fmt.Errorf("%w: %s", ErrRealmNotFound, realmID)
+	}
+	token := newSecret()
+	hash := sha256.Sum256([]byte(token))
+	info := &RegistrationTokenInfo{
+		CreatedAt: time.Now().UTC(),
+		UserID:    s.userID,
+		RealmID:   realmID,
+		TokenHash: hex.EncodeToString(hash[:]),
+		ExpiresAt: time.Now().UTC().Add(ttl),
+		TokenID:   newID(),
+		Tag:       tag,
+	}
+	s.registrationTokens[token] = info
+	return info.TokenID, token, nil
+}
+
+// Confluxes returns the registered confluxes, oldest first.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - []Conflux. Copies of the confluxes.
+func (s *Server) Confluxes() []Conflux {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	confluxes := make([]Conflux, 0, len(s.confluxes))
+	for _, conflux := range s.confluxes {
+		confluxes = append(confluxes, *conflux)
+	}
+	return confluxes
+}
+
+// SetLocalNetworks replaces the subnets the Guardian knows a conflux advertises.
+//
+// Inputs:
+//   - confluxID: string. The conflux.
+//   - networks: []LocalNetwork. The local networks.
+//
+// Outputs:
+//   - err: error. Wraps ErrConfluxNotFound if the conflux is not registered.
+func (s *Server) SetLocalNetworks(confluxID string, networks []LocalNetwork) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conflux(confluxID) == nil {
+		return fmt.Errorf("%w: %s", ErrConfluxNotFound, confluxID)
+	}
+	s.localNetworks[confluxID] = slices.Clone(networks)
+	return nil
+}
+
+// SetRemoteNetworks replaces the subnets the Guardian knows a conflux reaches through its peers.
+//
+// Inputs:
+//   - confluxID: string. The conflux.
+//   - networks: []RemoteNetwork. The remote networks.
+//
+// Outputs:
+//   - err: error. Wraps ErrConfluxNotFound if the conflux is not registered.
+func (s *Server) SetRemoteNetworks(confluxID string, networks []RemoteNetwork) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conflux(confluxID) == nil {
+		return fmt.Errorf("%w: %s", ErrConfluxNotFound, confluxID)
+	}
+	s.remoteNetworks[confluxID] = slices.Clone(networks)
+	return nil
+}
+
+// realm returns the realm with id; the lock must be held.
+func (s *Server) realm(id string) *Realm {
+	for _, realm := range s.realms {
+		if realm.ID == id {
+			return realm
+		}
+	}
+	return nil
+}
+
+// conflux returns the conflux with id; the lock must be held.
+func (s *Server) conflux(id string) *Conflux {
+	for _, conflux := range s.confluxes {
+		if conflux.ID == id {
+			return conflux
+		}
+	}
+	return nil
+}
+
+// register registers a new conflux in the realm of the registration token with cidr, or the first free address if
+// cidr is empty; the lock must be held.
+func (s *Server) register(token *RegistrationTokenInfo, tag string, cidr string) (registration, error) {
+	realm := s.realm(token.RealmID)
+	if realm == nil {
+		return registration{}, fmt.Errorf("%w: %s", ErrRealmNotFound, token.RealmID)
+	}
+	if tag == "" {
+		tag = token.Tag
+	}
+
+	// Reserve the address
+	if cidr != "" {
+		for _, conflux := range s.confluxes {
+			if conflux.PlaneID == realm.ID && conflux.CIDR == cidr {
+				return registration{}, fmt.Errorf("%w: %s is taken", ErrIPNotAvailable, cidr)
+			}
+		}
+	} else {
+		var err error
+		if cidr, err = s.allocate(realm); err != nil {
+			return registration{}, err
+		}
+	}
+
+	// Register a new conflux
+	conflux := &Conflux{
+		ID:        newID(),
+		CreatedAt: time.Now().UTC(),
+		UserID:    token.UserID,
+		Tag:       tag,
+		IPLeaseID: newID(),
+		CIDR:      cidr,
+		Subnet:    realm.Subnet,
+		Plane:     realm.Name,
+		PlaneID:   realm.ID,
+		VeilHost:  realm.VeilHost,
+		VeilPort:  realm.VeilPort,
+		Region:    realm.Region,
+	}
+	s.confluxes = append(s.confluxes, conflux)
+	return s.mintConfluxToken(conflux.ID), nil
+}
+
+// mintConfluxToken issues a token for a new conflux; the lock must be held.
+func (s *Server) mintConfluxToken(confluxID string) registration {
+	token := newSecret()
+	s.confluxTokens[token] = confluxID
+	return registration{ConfluxID: confluxID, Token: token}
+}
+
+// unregister removes a conflux, its token and its networks; the lock must be held.
+func (s *Server) unregister(conflux *Conflux) {
+	s.confluxes = slices.DeleteFunc(s.confluxes, func(c *Conflux) bool { return c == conflux })
+	delete(s.localNetworks, conflux.ID)
+	delete(s.remoteNetworks, conflux.ID)
+	for token, id := range s.confluxTokens {
+		if id == conflux.ID {
+			delete(s.confluxTokens, token)
+		}
+	}
+}
+
+// allocate returns the first free host address of the realm subnet as a CIDR with the subnet prefix; the lock must
+// be held.
+func (s *Server) allocate(realm *Realm) (string, error) {
+	subnet := netip.MustParsePrefix(realm.Subnet)
+	taken := map[netip.Addr]bool{}
+	for _, conflux := range s.confluxes {
+		if conflux.PlaneID != realm.ID {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(conflux.CIDR); err == nil {
+			taken[prefix.Addr()] = true
+		}
+	}
+	// Skip the network address and the first host, kept for the veil
+	for addr := subnet.Addr().Next().Next(); subnet.Contains(addr); addr = addr.Next() {
+		if !taken[addr] && subnet.Contains(addr.Next()) {
+			return netip.PrefixFrom(addr, subnet.Bits()).String(), nil
+		}
+	}
+	return "", fmt.Errorf("%w: no free address left in %s", ErrIPNotAvailable, realm.Subnet)
+}
+
+// validateCIDR checks that cidr is an address of the realm subnet with its prefix, e.g. 10.128.0.5/16.
+func validateCIDR(realm *Realm, cidr string) error {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return errors.New("value is not a valid CIDR")
+	}
+	subnet := netip.MustParsePrefix(realm.Subnet)
+	if prefix.Bits() != subnet.Bits() || !subnet.Contains(prefix.Addr()) {
+		return fmt.Errorf("value is not an address of the realm subnet %s", realm.Subnet)
+	}
+	return nil
+}
+
+// newID returns a random UUID v4 like the IDs of the Guardian.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return strings.Join([]string{h[0:8], h[8:12], h[12:16], h[16:20], h[20:32]}, "-")
+}
+
+// newSecret returns a random token.
+func newSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,421 @@
+package guardiantest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Handler returns the HTTP handler of the stand-in, e.g. to mount it in an httptest.Server.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - http.Handler. The handler serving the Guardian endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("POST /auth/login", s.handleLogin)
+	mux.HandleFunc("GET /auth/profile", s.user(s.handleProfile))
+	mux.HandleFunc("POST /auth/create/registration-token", s.user(s.handleCreateRegistrationToken))
+	mux.HandleFunc("GET /auth/list/registration-token", s.user(s.handleListRegistrationTokens))
+	mux.HandleFunc("DELETE /auth/revoke/registration-token", s.user(s.handleRevokeRegistrationToken))
+	mux.HandleFunc("GET /realm", s.user(s.handleGetRealm))
+	mux.HandleFunc("GET /realm/list", s.user(s.handleListRealms))
+	mux.HandleFunc("GET /conflux", s.user(s.handleGetConflux))
+	mux.HandleFunc("DELETE /conflux", s.user(s.handleDeleteConflux))
+	mux.HandleFunc("GET /conflux/list", s.user(s.handleListConfluxes))
+	mux.HandleFunc("GET /conflux/local-network", s.user(s.handleLocalNetworks))
+	mux.HandleFunc("GET /conflux/remote-network", s.user(s.handleRemoteNetworks))
+	mux.HandleFunc("POST /conflux/register", s.handleRegister)
+	mux.HandleFunc("DELETE /conflux/unregister", s.handleUnregister)
+	mux.HandleFunc("POST /conflux/session/logout", s.handleLogout)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeDetail(w, http.StatusNotFound, "Not Found")
+	})
+	return mux
+}
+
+// user wraps a handler of an OAuth2 endpoint, rejecting requests without the access token of the user.
+func (s *Server) user(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearer(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeDetail(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+		if token != s.options.AccessToken {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeDetail(w, http.StatusUnauthorized, "Could not validate credentials")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// handleHealth answers the health probe of doctor.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleLogin exchanges the email and password of the user for the access token.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeDetail(w, http.StatusBadRequest, "invalid form body")
+		return
+	}
+	var missing []ValidationError
+	for _, field := range []string{"username", "password"} {
+		if r.PostForm.Get(field) == "" {
+			missing = append(missing, fieldRequired("body", field))
+		}
+	}
+	if len(missing) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, HTTPValidationError{Detail: missing})
+		return
+	}
+	if r.PostForm.Get("username") != s.options.Email || r.PostForm.Get("password") != s.options.Password {
+		writeDetail(w, http.StatusUnauthorized, "Incorrect username or password")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": s.options.AccessToken, "token_type": "bearer"})
+}
+
+// handleProfile returns the profile of the user.
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":           s.userID,
+		"email":        s.options.Email,
+		"is_superuser": false,
+		"mp":           0,
+		"display_name": nil,
+	})
+}
+
+// handleCreateRegistrationToken mints a registration token for a realm.
+func (s *Server) handleCreateRegistrationToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RealmID      *string `json:"realm_id"`
+		ExpiresAfter *int    `json:"expires_after"`
+		Tag          string  `json:"tag"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	var invalid []ValidationError
+	if body.RealmID == nil {
+		invalid = append(invalid, fieldRequired("body", "realm_id"))
+	}
+	if body.ExpiresAfter == nil {
+		invalid = append(invalid, fieldRequired("body", "expires_after"))
+	} else if *body.ExpiresAfter < 1 {
+		invalid = append(invalid, ValidationError{Loc: []any{"body", "expires_after"}, Msg: "Input should be greater than or equal to 1", Type: "greater_than_equal"})
+	}
+	if len(invalid) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, HTTPValidationError{Detail: invalid})
+		return
+	}
+
+	tokenID, token, err := s.CreateRegistrationToken(*body.RealmID, body.Tag, time.Duration(*body.ExpiresAfter)*24*time.Hour)
+	if errors.Is(err, ErrRealmNotFound) {
+		writeDetail(w, http.StatusNotFound, "Realm not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"token_id": tokenID, "token": token})
+}
+
+// handleListRegistrationTokens lists the registration tokens of the user.
+func (s *Server) handleListRegistrationTokens(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	tokens := make([]RegistrationTokenInfo, 0, len(s.registrationTokens))
+	for _, info := range s.registrationTokens {
+		tokens = append(tokens, *info)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(tokens, func(a, b RegistrationTokenInfo) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// handleRevokeRegistrationToken revokes a registration token; confluxes registered with it stay registered.
+func (s *Server) handleRevokeRegistrationToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, ok := queryParameter(w, r, "token_id")
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, info := range s.registrationTokens {
+		if info.TokenID == tokenID {
+			delete(s.registrationTokens, token)
+			writeJSON(w, http.StatusOK, nil)
+			return
+		}
+	}
+	writeDetail(w, http.StatusNotFound, "Registration token not found")
+}
+
+// handleGetRealm returns a realm.
+func (s *Server) handleGetRealm(w http.ResponseWriter, r *http.Request) {
+	realmID, ok := queryParameter(w, r, "realm_id")
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	realm := s.realm(realmID)
+	if realm == nil {
+		writeDetail(w, http.StatusNotFound, "Realm not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, realm)
+}
+
+// handleListRealms lists the realms of the user.
+func (s *Server) handleListRealms(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.realms)
+}
+
+// handleGetConflux returns a conflux.
+func (s *Server) handleGetConflux(w http.ResponseWriter, r *http.Request) {
+	confluxID, ok := queryParameter(w, r, "conflux_id")
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conflux := s.conflux(confluxID)
+	if conflux == nil {
+		writeDetail(w, http.StatusNotFound, "Conflux not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, conflux)
+}
+
+// handleDeleteConflux deletes a conflux as its owner and returns it.
+func (s *Server) handleDeleteConflux(w http.ResponseWriter, r *http.Request) {
+	confluxID, ok := queryParameter(w, r, "conflux_id")
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conflux := s.conflux(confluxID)
+	if conflux == nil {
+		writeDetail(w, http.StatusNotFound, "Conflux not found")
+		return
+	}
+	s.unregister(conflux)
+	writeJSON(w, http.StatusOK, conflux)
+}
+
+// handleListConfluxes lists the confluxes of the user.
+func (s *Server) handleListConfluxes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.confluxes)
+}
+
+// handleLocalNetworks returns the subnets a conflux advertises.
+func (s *Server) handleLocalNetworks(w http.ResponseWriter, r *http.Request) {
+	confluxID, ok := queryParameter(w, r, "conflux_id")
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conflux(confluxID) == nil {
+		writeDetail(w, http.StatusNotFound, "Conflux not found")
+		return
+	}
+	networks := s.localNetworks[confluxID]
+	if networks == nil {
+		networks = []LocalNetwork{}
+	}
+	writeJSON(w, http.StatusOK, networks)
+}
+
+// handleRemoteNetworks returns the subnets a conflux reaches through its peers.
+func (s *Server) handleRemoteNetworks(w http.ResponseWriter, r *http.Request) {
+	confluxID, ok := queryParameter(w, r, "conflux_id")
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conflux(confluxID) == nil {
+		writeDetail(w, http.StatusNotFound, "Conflux not found")
+		return
+	}
+	networks := s.remoteNetworks[confluxID]
+	if networks == nil {
+		networks = []RemoteNetwork{}
+	}
+	writeJSON(w, http.StatusOK, networks)
+}
+
+// handleRegister registers a new conflux with a registration token, at the requested CIDR if it is free.
+func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
+	token, ok := s.registrationToken(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Tag  string `json:"tag"`
+		CIDR string `json:"cidr"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if body.CIDR != "" {
+		realm := s.realm(token.RealmID)
+		if realm == nil {
+			writeDetail(w, http.StatusNotFound, "Realm not found")
+			return
+		}
+		if err := validateCIDR(realm, body.CIDR); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, HTTPValidationError{Detail: []ValidationError{
+				{Loc: []any{"body", "cidr"}, Msg: "Value error, " + err.Error(), Type: "value_error"},
+			}})
+			return
+		}
+	}
+	response, err := s.register(token, body.Tag, body.CIDR)
+	if errors.Is(err, ErrRealmNotFound) {
+		writeDetail(w, http.StatusNotFound, "Realm not found")
+		return
+	}
+	if errors.Is(err, ErrIPNotAvailable) {
+		writeDetail(w, http.StatusBadRequest, "IP not available")
+		return
+	}
+	if err != nil {
+		writeDetail(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleUnregister unregisters a conflux of the user of the registration token.
+func (s *Server) handleUnregister(w http.ResponseWriter, r *http.Request) {
+	token, ok := s.registrationToken(w, r)
+	if !ok {
+		return
+	}
+	confluxID, ok := queryParameter(w, r, "conflux_id")
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conflux := s.conflux(confluxID)
+	if conflux == nil || conflux.UserID != token.UserID {
+		writeDetail(w, http.StatusNotFound, "Conflux not found")
+		return
+	}
+	s.unregister(conflux)
+	writeJSON(w, http.StatusOK, nil)
+}
+
+// handleLogout ends the session of the conflux of the conflux token, deleting the conflux unless it is a portal.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("x-conflux-token")
+	if token == "" {
+		writeDetail(w, http.StatusForbidden, "Not authenticated")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	confluxID, ok := s.confluxTokens[token]
+	if !ok {
+		writeDetail(w, http.StatusUnauthorized, "Invalid conflux token")
+		return
+	}
+	delete(s.confluxTokens, token)
+	if conflux := s.conflux(confluxID); conflux != nil && !conflux.Portal {
+		s.unregister(conflux)
+	}
+	writeJSON(w, http.StatusOK, nil)
+}
+
+// registrationToken returns the valid registration token the request is authenticated with, writing the error
+// response otherwise.
+func (s *Server) registrationToken(w http.ResponseWriter, r *http.Request) (*RegistrationTokenInfo, bool) {
+	token, ok := bearer(r)
+	if !ok {
+		writeDetail(w, http.StatusForbidden, "Not authenticated")
+		return nil, false
+	}
+	s.mu.Lock()
+	info := s.registrationTokens[token]
+	s.mu.Unlock()
+	if info == nil {
+		writeDetail(w, http.StatusUnauthorized, "Invalid registration token")
+		return nil, false
+	}
+	if time.Now().After(info.ExpiresAt) {
+		writeDetail(w, http.StatusUnauthorized, "Registration token expired")
+		return nil, false
+	}
+	return info, true
+}
+
+// bearer returns the bearer token of the request.
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// queryParameter returns a required query parameter, writing the validation error if it is missing.
+func queryParameter(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, HTTPValidationError{Detail: []ValidationError{fieldRequired("query", name)}})
+		return "", false
+	}
+	return value, true
+}
+
+// decodeBody decodes the JSON body of the request into v, writing the validation error if it is invalid.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	detail := ValidationError{Loc: []any{"body"}, Msg: "JSON decode error", Type: "json_invalid"}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		detail = ValidationError{Loc: []any{"body", typeErr.Field}, Msg: fmt.Sprintf("Input should be a valid %s", typeErr.Type), Type: "type_error"}
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, HTTPValidationError{Detail: []ValidationError{detail}})
+	return false
+}
+
+// fieldRequired is the validation error of a missing field.
+func fieldRequired(location string, name string) ValidationError {
+	return ValidationError{Loc: []any{location, name}, Msg: "Field required", Type: "missing"}
+}
+
+// writeDetail writes an error response with the {"detail": message} body of the Guardian.
+func writeDetail(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"detail": message})
+}
+
+// writeJSON writes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
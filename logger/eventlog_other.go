@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import "go.uber.org/zap/zapcore"
+
+// platformCores returns no additional cores outside Windows; journald and launchd capture stderr or the log file.
+//
+// Inputs:
+//   - level: zapcore.LevelEnabler. Unused.
+//
+// Outputs:
+//   - []zapcore.Core. Always nil.
+func platformCores(level zapcore.LevelEnabler) []zapcore.Core {
+	return nil
+}
@@ -0,0 +1,113 @@
+//go:build windows
+// +build windows
+
+package logger
+
+import (
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventSource is the event log source installed by the Windows service.
+const eventSource = "VeilNet Conflux"
+
+// Event IDs used for log entries forwarded to the event log.
+const (
+	eventIDInfo    = 1100
+	eventIDWarning = 2100
+	eventIDError   = 3100
+)
+
+var (
+	eventLogOnce sync.Once
+	eventLog     *eventlog.Log
+)
+
+// platformCores returns an event log core when running as a Windows service.
+//
+// Inputs:
+//   - level: zapcore.LevelEnabler. The minimum level to forward; debug entries are never forwarded.
+//
+// Outputs:
+//   - []zapcore.Core. The event log core, or nil when not running as a service or the source cannot be opened.
+func platformCores(level zapcore.LevelEnabler) []zapcore.Core {
+	isWindowsService, err := svc.IsWindowsService()
+	if err != nil || !isWindowsService {
+		return nil
+	}
+	eventLogOnce.Do(func() {
+		eventLog, _ = eventlog.Open(eventSource)
+	})
+	if eventLog == nil {
+		return nil
+	}
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.TimeKey = ""
+	encoderConfig.LevelKey = ""
+	return []zapcore.Core{&eventLogCore{
+		LevelEnabler: level,
+		encoder:      zapcore.NewConsoleEncoder(encoderConfig),
+		log:          eventLog,
+	}}
+}
+
+// eventLogCore is a zapcore.Core writing entries to the Windows event log.
+type eventLogCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	log     *eventlog.Log
+}
+
+// Enabled reports whether the level is forwarded; debug entries are kept out of the event log.
+func (c *eventLogCore) Enabled(level zapcore.Level) bool {
+	return level > zapcore.DebugLevel && c.LevelEnabler.Enabled(level)
+}
+
+// With returns a copy of the core with the fields added to its encoder.
+func (c *eventLogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &eventLogCore{
+		LevelEnabler: c.LevelEnabler,
+		encoder:      c.encoder.Clone(),
+		log:          c.log,
+	}
+	for _, field := range fields {
+		field.AddTo(clone.encoder)
+	}
+	return clone
+}
+
+// Check adds the core to the checked entry if the level is enabled.
+func (c *eventLogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write encodes the entry and reports it with the event type matching its level.
+func (c *eventLogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	msg := strings.TrimSpace(buf.String())
+	buf.Free()
+	switch {
+	case entry.Level >= zapcore.ErrorLevel:
+		return c.log.Error(eventIDError, msg)
+	case entry.Level == zapcore.WarnLevel:
+		return c.log.Warning(eventIDWarning, msg)
+	default:
+		return c.log.Info(eventIDInfo, msg)
+	}
+}
+
+// Sync is a no-op; the event log is written synchronously.
+func (c *eventLogCore) Sync() error {
+	return nil
+}
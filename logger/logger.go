@@ -2,14 +2,44 @@
 package logger
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Logger is the global zap logger.
 // Use Logger.Sugar() to get a SugaredLogger which supports Infof, Errorf, etc.
+//
+// The pointer and its content never change: entries are forwarded to the logger returned by L(), so packages that
+// captured it at init (e.g. via `var Logger = logger.Logger`) pick up the settings of Configure.
 var Logger *zap.Logger
 
+// current holds the logger built by init or the last Configure.
+var current atomic.Pointer[zap.Logger]
+
+// Options holds the logger settings (level, format, output file and rotation).
+type Options struct {
+	Level      string `json:"level"`
+	Format     string `json:"format"`
+	File       string `json:"file"`
+	MaxSizeMB  int    `json:"max_size_mb"`
+	MaxAgeDays int    `json:"max_age_days"`
+	MaxBackups int    `json:"max_backups"`
+}
+
+// Default rotation settings applied when logging to a file.
+const (
+	DefaultMaxSizeMB  = 50
+	DefaultMaxAgeDays = 14
+	DefaultMaxBackups = 5
+)
+
 // init initializes the development zap config with colors and ISO8601 time format.
 //
 // Inputs: none.
@@ -28,10 +58,122 @@ func init() {
 	// Disable stacktrace
 	config.DisableStacktrace = true
 
-	var err error
-	Logger, err = config.Build()
+	built, err := config.Build()
 	if err != nil {
 		// Fallback if zap fails to initialize
 		panic(err)
 	}
+	current.Store(built)
+	Logger = zap.New(currentCore{})
+}
+
+// L returns the logger built by the last Configure, or the development logger before it.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *zap.Logger. The current logger.
+func L() *zap.Logger {
+	return current.Load()
+}
+
+// currentCore is a zapcore.Core forwarding every call to the core of the current logger, so Configure can swap it
+// while other goroutines log.
+type currentCore struct{}
+
+// Enabled reports whether the current core logs the level.
+func (currentCore) Enabled(level zapcore.Level) bool {
+	return L().Core().Enabled(level)
+}
+
+// With returns the current core with the fields added; the result keeps that core across later Configure calls.
+func (currentCore) With(fields []zapcore.Field) zapcore.Core {
+	return L().Core().With(fields)
+}
+
+// Check adds the current core to the checked entry if it logs the entry.
+func (currentCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return L().Core().Check(entry, checked)
+}
+
+// Write writes the entry to the current core.
+func (currentCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return L().Core().Write(entry, fields)
+}
+
+// Sync flushes the current core.
+func (currentCore) Sync() error {
+	return L().Core().Sync()
+}
+
+// Configure rebuilds the global logger from options.
+//
+// The new logger is swapped in atomically, so goroutines already logging through Logger are not raced.
+//
+// Inputs:
+//   - options: Options. Level (debug, info, warn, error; default debug), format (console or json; default console),
+//     file (empty for stderr) and rotation limits (zero values use the defaults).
+//
+// Outputs:
+//   - err: error. Non-nil if an option is invalid or the log file directory cannot be created.
+func Configure(options Options) error {
+	level := zapcore.DebugLevel
+	if options.Level != "" {
+		parsed, err := zapcore.ParseLevel(options.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q: %w", options.Level, err)
+		}
+		level = parsed
+	}
+
+	// Select the output
+	var output zapcore.WriteSyncer
+	if options.File == "" {
+		output = zapcore.Lock(os.Stderr)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(options.File), 0755); err != nil {
+			return err
+		}
+		rotation := &lumberjack.Logger{
+			Filename:   options.File,
+			MaxSize:    options.MaxSizeMB,
+			MaxAge:     options.MaxAgeDays,
+			MaxBackups: options.MaxBackups,
+			Compress:   true,
+		}
+		if rotation.MaxSize <= 0 {
+			rotation.MaxSize = DefaultMaxSizeMB
+		}
+		if rotation.MaxAge <= 0 {
+			rotation.MaxAge = DefaultMaxAgeDays
+		}
+		if rotation.MaxBackups <= 0 {
+			rotation.MaxBackups = DefaultMaxBackups
+		}
+		output = zapcore.AddSync(rotation)
+	}
+
+	// Select the encoder
+	var encoder zapcore.Encoder
+	switch strings.ToLower(options.Format) {
+	case "", "console":
+		encoderConfig := zap.NewDevelopmentEncoderConfig()
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		// Only colorize levels on a terminal, never in files
+		if options.File == "" {
+			encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	case "json":
+		encoderConfig := zap.NewProductionEncoderConfig()
+		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	default:
+		return fmt.Errorf("invalid log format %q: must be console or json", options.Format)
+	}
+
+	cores := []zapcore.Core{zapcore.NewCore(encoder, output, level)}
+	cores = append(cores, platformCores(level)...)
+	current.Store(zap.New(zapcore.NewTee(cores...)))
+	return nil
 }
@@ -2,26 +2,34 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/alecthomas/kong"
+	"github.com/veil-net/conflux/buildinfo"
 	"github.com/veil-net/conflux/cli"
 )
 
 // version is the internal version string used by kong for the CLI.
 var version = "Beta-v1.0.11"
 
-// main parses the CLI with kong, runs the selected command, and exits with 1 on error.
+// main parses the CLI with kong, runs the selected command, and exits with a non-zero code on error.
 //
 // Inputs: none.
 //
-// Outputs: none. Exits with code 0 on success or 1 if the selected command returns an error.
+// Outputs: none. Exits with code 0 on success, the error's exit code if it implements kong.ExitCoder, or 1 otherwise.
 func main() {
+	buildinfo.Version = version
+
 	// Parse the CLI arguments
 	var cli cli.CLI
 	ctx := kong.Parse(&cli, kong.Vars{"version": version})
-	err := ctx.Run()
+	err := ctx.Run(&cli.Globals)
 	if err != nil {
+		var exitCoder kong.ExitCoder
+		if errors.As(err, &exitCoder) {
+			os.Exit(exitCoder.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
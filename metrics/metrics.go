@@ -0,0 +1,175 @@
+// Package metrics provides the Prometheus metrics exposed by the conflux wrapper.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Registry holds every conflux metric plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Up is 1 when the last poll of the anchor succeeded and 0 otherwise.
+	Up = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "conflux_up",
+		Help: "Whether the last poll of the anchor succeeded (1) or not (0).",
+	})
+
+	// Info carries the conflux identity as labels; its value is always 1.
+	Info = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "conflux_info",
+		Help: "Conflux identity, realm and veil as labels; always 1.",
+	}, []string{"id", "tag", "cidr", "realm", "realm_id", "subnet", "veil_host", "region"})
+
+	// Tethers is the number of tethers reported by the anchor.
+	Tethers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "conflux_tethers",
+		Help: "Number of tethers reported by the anchor.",
+	}, []string{"realm", "veil"})
+
+	// Routes is the number of routes reported by the anchor.
+	Routes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "conflux_routes",
+		Help: "Number of routes reported by the anchor.",
+	}, []string{"realm", "veil"})
+
+	// Streams is the number of streams reported by the anchor.
+	Streams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "conflux_streams",
+		Help: "Number of streams reported by the anchor.",
+	}, []string{"realm", "veil"})
+
+	// AnchorRestarts counts restarts of the anchor subprocess after an unexpected exit.
+	AnchorRestarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "conflux_anchor_restarts_total",
+		Help: "Number of times the anchor subprocess was restarted after exiting unexpectedly.",
+	})
+
+	// GRPCDuration observes the latency of anchor gRPC calls.
+	GRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "conflux_grpc_client_duration_seconds",
+		Help:    "Latency of gRPC calls to the anchor.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	// GRPCErrors counts anchor gRPC calls that returned an error.
+	GRPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "conflux_grpc_client_errors_total",
+		Help: "Number of gRPC calls to the anchor that returned an error.",
+	}, []string{"method", "code"})
+
+	// Registrations counts Guardian registrations by outcome (success or failure).
+	Registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "conflux_registrations_total",
+		Help: "Number of conflux registrations with the Guardian by outcome.",
+	}, []string{"outcome"})
+)
+
+// init registers every metric with Registry.
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Up,
+		Info,
+		Tethers,
+		Routes,
+		Streams,
+		AnchorRestarts,
+		GRPCDuration,
+		GRPCErrors,
+		Registrations,
+	)
+}
+
+// Handler returns the HTTP handler serving Registry in the Prometheus text format.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - http.Handler. The /metrics handler.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// SetAnchorState records a successful poll of the anchor.
+//
+// Inputs:
+//   - info: *pb.GetInfoResponse. Conflux info with the tether, route and stream counts.
+//   - realm: *pb.GetRealmInfoResponse. Realm info used for labels.
+//   - veil: *pb.GetVeilInfoResponse. Veil info used for labels.
+//
+// Outputs: none.
+func SetAnchorState(info *pb.GetInfoResponse, realm *pb.GetRealmInfoResponse, veil *pb.GetVeilInfoResponse) {
+	// Reset so that series with stale labels (e.g. after a realm change) disappear
+	Info.Reset()
+	Tethers.Reset()
+	Routes.Reset()
+	Streams.Reset()
+
+	Info.WithLabelValues(
+		info.GetId(),
+		info.GetTag(),
+		info.GetCidr(),
+		realm.GetRealm(),
+		realm.GetRealmId(),
+		realm.GetSubnet(),
+		veil.GetVeilHost(),
+		veil.GetRegion(),
+	).Set(1)
+	Tethers.WithLabelValues(realm.GetRealm(), veil.GetVeilHost()).Set(float64(info.GetNumberOfTethers()))
+	Routes.WithLabelValues(realm.GetRealm(), veil.GetVeilHost()).Set(float64(info.GetNumberOfRoutes()))
+	Streams.WithLabelValues(realm.GetRealm(), veil.GetVeilHost()).Set(float64(info.GetNumberOfStreams()))
+	Up.Set(1)
+}
+
+// SetAnchorDown records a failed poll of the anchor.
+//
+// Inputs: none.
+//
+// Outputs: none.
+func SetAnchorDown() {
+	Up.Set(0)
+}
+
+// RecordRegistration counts a registration attempt.
+//
+// Inputs:
+//   - err: error. The registration error; nil counts as success.
+//
+// Outputs: none.
+func RecordRegistration(err error) {
+	if err != nil {
+		Registrations.WithLabelValues("failure").Inc()
+		return
+	}
+	Registrations.WithLabelValues("success").Inc()
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor recording the latency and errors of anchor calls.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - grpc.UnaryClientInterceptor. The interceptor to install on the anchor client.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		code := status.Code(err).String()
+		GRPCDuration.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+		if err != nil {
+			GRPCErrors.WithLabelValues(method, code).Inc()
+		}
+		return err
+	}
+}
@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+)
+
+// callbackPath is the path of the loopback redirect URI.
+const callbackPath = "/callback"
+
+// BrowserLogin runs the authorization code flow with PKCE through a loopback redirect: it listens on 127.0.0.1, hands
+// the authorization URL to open and exchanges the code it receives for the tokens. The provider must accept
+// http://127.0.0.1:<any port>/callback as a redirect URI of the client (RFC 8252).
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the login, including the wait for the user.
+//   - config: *Config. The client ID, scopes and HTTP client.
+//   - provider: *Provider. The discovered provider.
+//   - open: func(string) error. Shows the authorization URL, e.g. OpenBrowser.
+//
+// Outputs:
+//   - *Token. The token response holding the ID token.
+//   - err: error. Non-nil if the user denies the login, the state does not match or the code exchange fails.
+func BrowserLogin(ctx context.Context, config *Config, provider *Provider, open func(string) error) (*Token, error) {
+	if provider.AuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("%s has no authorization endpoint, use the device flow", provider.Issuer)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+	redirectURI := fmt.Sprintf("http://%s%s", listener.Addr(), callbackPath)
+
+	// Build the authorization URL with a PKCE challenge and a state bound to this login
+	verifier, state := randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL, err := url.Parse(provider.AuthorizationEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", config.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", config.scope())
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	// Wait for the redirect
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != callbackPath {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		var res result
+		switch {
+		case query.Get("state") != state:
+			res.err = errors.New("the login callback has an invalid state")
+		case query.Get("error") != "":
+			res.err = &tokenError{Code: query.Get("error"), Description: query.Get("error_description")}
+		case query.Get("code") == "":
+			res.err = errors.New("the login callback has no authorization code")
+		default:
+			res.code = query.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, "Login failed: "+res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Login complete, you can close this window and return to the terminal.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if err := open(authURL.String()); err != nil {
+		return nil, err
+	}
+	var res result
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("the browser login did not complete: %w", ctx.Err())
+	case res = <-results:
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	// Exchange the code for the tokens
+	return requestToken(ctx, config, provider.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {config.ClientID},
+		"code_verifier": {verifier},
+	})
+}
+
+// OpenBrowser opens url in the default browser of the desktop session.
+//
+// Inputs:
+//   - url: string. The URL to open.
+//
+// Outputs:
+//   - err: error. Non-nil if no browser could be started.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+// randomString returns 32 random bytes encoded for use as a PKCE verifier or state.
+func randomString() string {
+	data := make([]byte, 32)
+	rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,106 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// deviceGrantType is the grant type of the device authorization grant (RFC 8628).
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceCode is the device authorization response the user acts on.
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceLogin runs the device authorization grant: it asks for a user code, hands it to prompt and polls the token
+// endpoint until the user approves, denies or the code expires.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the login.
+//   - config: *Config. The client ID, scopes and HTTP client.
+//   - provider: *Provider. The discovered provider; it must have a device authorization endpoint.
+//   - prompt: func(*DeviceCode). Shows the verification URI and user code to the user.
+//
+// Outputs:
+//   - *Token. The token response holding the ID token.
+//   - err: error. Non-nil if the provider does not support the grant, the user denies it or the code expires.
+func DeviceLogin(ctx context.Context, config *Config, provider *Provider, prompt func(*DeviceCode)) (*Token, error) {
+	if provider.DeviceAuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("%s does not support the device authorization grant, use the browser flow", provider.Issuer)
+	}
+
+	// Ask for a device and user code
+	form := url.Values{"client_id": {config.ClientID}, "scope": {config.scope()}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.DeviceAuthorizationEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := config.client().Do(req)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to request a device code: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("failed to request a device code: %s: %s", resp.Status, string(body))
+	}
+	var code DeviceCode
+	if err := json.NewDecoder(resp.Body).Decode(&code); err != nil {
+		return nil, fmt.Errorf("invalid device authorization response: %w", err)
+	}
+	prompt(&code)
+
+	// Poll until the user acts, slowing down when the provider asks to
+	interval := 5 * time.Second
+	if code.Interval > 0 {
+		interval = time.Duration(code.Interval) * time.Second
+	}
+	if code.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("the device code expired before the login was approved")
+		case <-time.After(interval):
+		}
+		token, err := requestToken(ctx, config, provider.TokenEndpoint, url.Values{
+			"grant_type":  {deviceGrantType},
+			"device_code": {code.DeviceCode},
+			"client_id":   {config.ClientID},
+		})
+		var oauthErr *tokenError
+		switch {
+		case err == nil:
+			return token, nil
+		case errors.As(err, &oauthErr) && oauthErr.Code == "authorization_pending":
+		case errors.As(err, &oauthErr) && oauthErr.Code == "slow_down":
+			interval += 5 * time.Second
+		case errors.As(err, &oauthErr) && oauthErr.Code == "access_denied":
+			return nil, errors.New("the login was denied")
+		case errors.As(err, &oauthErr) && oauthErr.Code == "expired_token":
+			return nil, errors.New("the device code expired before the login was approved")
+		default:
+			Logger.Sugar().Errorf("failed to poll the token endpoint: %v", err)
+			return nil, err
+		}
+	}
+}
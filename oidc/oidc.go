@@ -0,0 +1,159 @@
+// Package oidc obtains the JWT a conflux registers with: an ID token from an OpenID Connect provider, using discovery
+// and either the device authorization grant or a loopback browser flow with PKCE, or the workload identity token the
+// platform provides (a projected Kubernetes service account token or a cloud instance identity).
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/veil-net/conflux/logger"
+)
+
+// Logger re-exports the global logger for the oidc package.
+var Logger = logger.Logger
+
+// DefaultScopes are requested when Config.Scopes is empty.
+var DefaultScopes = []string{"openid"}
+
+// Config holds the settings of a login.
+type Config struct {
+	// Issuer is the provider URL, discovery is read from Issuer/.well-known/openid-configuration.
+	Issuer string
+	// ClientID is the public client registered at the provider; it is the audience of the ID token.
+	ClientID string
+	// Scopes are the requested scopes, default: openid.
+	Scopes []string
+	// HTTPClient sends the provider requests, default: a client with a 30s timeout.
+	HTTPClient *http.Client
+}
+
+// Provider is the discovery document of an OpenID Connect provider.
+type Provider struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	JWKSURI                     string `json:"jwks_uri"`
+}
+
+// Token is the token response of the provider.
+type Token struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// tokenError is the error response of the token and device endpoints (RFC 6749 section 5.2).
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Error returns the error code with its description.
+func (e *tokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// client returns the HTTP client of the config.
+func (config *Config) client() *http.Client {
+	if config.HTTPClient != nil {
+		return config.HTTPClient
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// scope returns the space separated scopes of the config.
+func (config *Config) scope() string {
+	if len(config.Scopes) == 0 {
+		return strings.Join(DefaultScopes, " ")
+	}
+	return strings.Join(config.Scopes, " ")
+}
+
+// Discover reads the discovery document of the issuer and checks that it names the same issuer.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the request.
+//   - config: *Config. The issuer and HTTP client.
+//
+// Outputs:
+//   - *Provider. The discovery document.
+//   - err: error. Non-nil if the document cannot be fetched, is invalid or belongs to another issuer.
+func Discover(ctx context.Context, config *Config) (*Provider, error) {
+	issuer := strings.TrimSuffix(config.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := config.client().Do(req)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to fetch the OIDC discovery document: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("failed to fetch the OIDC discovery document: %s: %s", resp.Status, string(body))
+	}
+
+	var provider Provider
+	if err := json.NewDecoder(resp.Body).Decode(&provider); err != nil {
+		return nil, fmt.Errorf("invalid OIDC discovery document: %w", err)
+	}
+	if strings.TrimSuffix(provider.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery document is for issuer %q, not %q", provider.Issuer, config.Issuer)
+	}
+	if provider.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document of %s has no JWKS URI", config.Issuer)
+	}
+	return &provider, nil
+}
+
+// requestToken posts a form to an endpoint and decodes the token, or the OAuth error, it answers.
+func requestToken(ctx context.Context, config *Config, endpoint string, form url.Values) (*Token, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("%s has no token endpoint, it cannot issue ID tokens to this client", config.Issuer)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := config.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		oauthErr := &tokenError{}
+		if json.Unmarshal(body, oauthErr) == nil && oauthErr.Code != "" {
+			return nil, oauthErr
+		}
+		return nil, fmt.Errorf("token request failed: %s: %s", resp.Status, string(body))
+	}
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("the token response has no ID token, check that the openid scope is requested")
+	}
+	return &token, nil
+}
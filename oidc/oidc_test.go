@@ -0,0 +1,269 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testIDToken is the ID token the test issuer hands out.
+const testIDToken = "eyJhbGciOiJub25lIn0.eyJzdWIiOiJ1c2VyIn0."
+
+// testIssuer is an OpenID Connect provider supporting the device grant and the authorization code flow with PKCE.
+type testIssuer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu sync.Mutex
+	// pending is the number of polls answered authorization_pending before the device login completes.
+	pending int
+	// deny answers access_denied to the device polls.
+	deny bool
+	// challenges maps the authorization codes issued to their PKCE challenge and redirect URI.
+	challenges map[string][2]string
+	polls      int
+}
+
+// newTestIssuer starts a test issuer.
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{t: t, challenges: map[string][2]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", issuer.handleDiscovery)
+	mux.HandleFunc("POST /device", issuer.handleDevice)
+	mux.HandleFunc("GET /authorize", issuer.handleAuthorize)
+	mux.HandleFunc("POST /token", issuer.handleToken)
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+// config returns a login config for the issuer.
+func (issuer *testIssuer) config() *Config {
+	return &Config{Issuer: issuer.URL, ClientID: "conflux-cli", HTTPClient: issuer.Client()}
+}
+
+// handleDiscovery serves the discovery document.
+func (issuer *testIssuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(Provider{
+		Issuer:                      issuer.URL,
+		AuthorizationEndpoint:       issuer.URL + "/authorize",
+		TokenEndpoint:               issuer.URL + "/token",
+		DeviceAuthorizationEndpoint: issuer.URL + "/device",
+		JWKSURI:                     issuer.URL + "/jwks",
+	})
+}
+
+// handleDevice issues the device and user code.
+func (issuer *testIssuer) handleDevice(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("client_id") != "conflux-cli" || r.FormValue("scope") != "openid" {
+		http.Error(w, "bad device request", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(DeviceCode{
+		DeviceCode:      "device-1",
+		UserCode:        "ABCD-EFGH",
+		VerificationURI: issuer.URL + "/activate",
+		ExpiresIn:       60,
+		Interval:        1,
+	})
+}
+
+// handleAuthorize approves the login at once, redirecting to the client with a code bound to the PKCE challenge.
+func (issuer *testIssuer) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "conflux-cli" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+	issuer.mu.Lock()
+	issuer.challenges["code-1"] = [2]string{query.Get("code_challenge"), query.Get("redirect_uri")}
+	issuer.mu.Unlock()
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{"code": {"code-1"}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// handleToken answers the device polls and the code exchange, checking the PKCE verifier.
+func (issuer *testIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	issuer.mu.Lock()
+	defer issuer.mu.Unlock()
+	switch r.FormValue("grant_type") {
+	case deviceGrantType:
+		if r.FormValue("device_code") != "device-1" {
+			writeTokenError(w, "invalid_grant")
+			return
+		}
+		issuer.polls++
+		switch {
+		case issuer.deny:
+			writeTokenError(w, "access_denied")
+			return
+		case issuer.polls <= issuer.pending:
+			writeTokenError(w, "authorization_pending")
+			return
+		}
+	case "authorization_code":
+		challenge, ok := issuer.challenges[r.FormValue("code")]
+		delete(issuer.challenges, r.FormValue("code"))
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || challenge[0] != base64.RawURLEncoding.EncodeToString(verifier[:]) || challenge[1] != r.FormValue("redirect_uri") {
+			writeTokenError(w, "invalid_grant")
+			return
+		}
+	default:
+		writeTokenError(w, "unsupported_grant_type")
+		return
+	}
+	json.NewEncoder(w).Encode(Token{IDToken: testIDToken, AccessToken: "access-1", TokenType: "Bearer", ExpiresIn: 300})
+}
+
+// writeTokenError writes an OAuth error response.
+func writeTokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(tokenError{Code: code})
+}
+
+func TestDiscover(t *testing.T) {
+	issuer := newTestIssuer(t)
+	config := issuer.config()
+	config.Issuer += "/"
+	provider, err := Discover(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.TokenEndpoint != issuer.URL+"/token" {
+		t.Errorf("token endpoint = %q", provider.TokenEndpoint)
+	}
+
+	// A discovery document served for another issuer is rejected
+	other := newTestIssuer(t)
+	config.Issuer = other.URL
+	config.HTTPClient = &http.Client{Transport: rewriteHost{issuer.Listener.Addr().String()}}
+	if _, err := Discover(context.Background(), config); err == nil || !strings.Contains(err.Error(), "is for issuer") {
+		t.Errorf("err = %v, want an issuer mismatch", err)
+	}
+}
+
+// rewriteHost sends every request to host.
+type rewriteHost struct{ host string }
+
+// RoundTrip sends req to the rewritten host.
+func (rt rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Host = rt.host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDeviceLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.pending = 1
+	config := issuer.config()
+	provider, err := Discover(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prompted *DeviceCode
+	token, err := DeviceLogin(context.Background(), config, provider, func(code *DeviceCode) { prompted = code })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IDToken != testIDToken {
+		t.Errorf("ID token = %q, want %q", token.IDToken, testIDToken)
+	}
+	if prompted == nil || prompted.UserCode != "ABCD-EFGH" {
+		t.Errorf("prompt got %+v, want the user code", prompted)
+	}
+	if issuer.polls != 2 {
+		t.Errorf("polls = %d, want 2", issuer.polls)
+	}
+}
+
+func TestDeviceLoginDenied(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.deny = true
+	config := issuer.config()
+	provider, err := Discover(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeviceLogin(context.Background(), config, provider, func(*DeviceCode) {}); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("err = %v, want the login denied", err)
+	}
+}
+
+func TestDeviceLoginUnsupported(t *testing.T) {
+	provider := &Provider{Issuer: "https://issuer.example.com", TokenEndpoint: "https://issuer.example.com/token"}
+	if _, err := DeviceLogin(context.Background(), &Config{}, provider, func(*DeviceCode) {}); err == nil {
+		t.Error("expected an error without a device authorization endpoint")
+	}
+}
+
+func TestBrowserLogin(t *testing.T) {
+	issuer := newTestIssuer(t)
+	config := issuer.config()
+	provider, err := Discover(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The browser follows the authorization URL, whose redirect lands on the loopback callback
+	open := func(authURL string) error {
+		go func() {
+			resp, err := http.Get(authURL)
+			if err != nil {
+				t.Errorf("browser: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	token, err := BrowserLogin(ctx, config, provider, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IDToken != testIDToken {
+		t.Errorf("ID token = %q, want %q", token.IDToken, testIDToken)
+	}
+}
+
+func TestBrowserLoginRejectsForgedState(t *testing.T) {
+	issuer := newTestIssuer(t)
+	config := issuer.config()
+	provider, err := Discover(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An attacker delivers a code to the callback without knowing the state of the login
+	open := func(authURL string) error {
+		parsed, _ := url.Parse(authURL)
+		callback, _ := url.Parse(parsed.Query().Get("redirect_uri"))
+		callback.RawQuery = url.Values{"code": {"stolen"}, "state": {"forged"}}.Encode()
+		go func() {
+			resp, err := http.Get(callback.String())
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := BrowserLogin(ctx, config, provider, open); err == nil || !strings.Contains(err.Error(), "invalid state") {
+		t.Errorf("err = %v, want an invalid state", err)
+	}
+}
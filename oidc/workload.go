@@ -0,0 +1,132 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Claims are the JWT claims used to register with a workload identity token.
+type Claims struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Audience []string `json:"aud"`
+}
+
+// UnmarshalJSON decodes the claims, accepting aud as a string or a list.
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Issuer   string          `json:"iss"`
+		Subject  string          `json:"sub"`
+		Audience json.RawMessage `json:"aud"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Issuer, c.Subject, c.Audience = raw.Issuer, raw.Subject, nil
+	if len(raw.Audience) == 0 {
+		return nil
+	}
+	var audience string
+	if err := json.Unmarshal(raw.Audience, &audience); err == nil {
+		c.Audience = []string{audience}
+		return nil
+	}
+	return json.Unmarshal(raw.Audience, &c.Audience)
+}
+
+// ParseClaims decodes the claims of a JWT without verifying its signature; the Guardian verifies it against the JWKS.
+//
+// Inputs:
+//   - jwt: string. The compact JWT.
+//
+// Outputs:
+//   - *Claims. The issuer, subject and audience.
+//   - err: error. Non-nil if the token is not a JWT or has no issuer.
+func ParseClaims(jwt string) (*Claims, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the identity token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("the identity token payload is not base64url: %w", err)
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("the identity token payload is invalid: %w", err)
+	}
+	if claims.Issuer == "" {
+		return nil, errors.New("the identity token has no issuer")
+	}
+	return &claims, nil
+}
+
+// ReadTokenFile reads a workload identity JWT from a file, e.g. a projected Kubernetes service account token
+// (the kubelet refreshes the file, so it is read at each registration).
+//
+// Inputs:
+//   - path: string. The token file.
+//
+// Outputs:
+//   - string. The JWT.
+//   - err: error. Non-nil if the file cannot be read or is empty.
+func ReadTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to read the identity token: %v", err)
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("the identity token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// FetchToken fetches an instance identity JWT from a cloud metadata URL, e.g.
+// http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/identity?audience=veilnet
+// with the header Metadata-Flavor=Google.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the request.
+//   - config: *Config. The HTTP client; the issuer and client ID are not used.
+//   - url: string. The metadata URL answering the JWT as the response body.
+//   - headers: map[string]string. Headers the metadata service requires.
+//
+// Outputs:
+//   - string. The JWT.
+//   - err: error. Non-nil if the request fails or answers an empty body.
+func FetchToken(ctx context.Context, config *Config, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := config.client().Do(req)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to fetch the identity token: %v", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch the identity token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	token := strings.TrimSpace(string(body))
+	if token == "" {
+		return "", fmt.Errorf("the metadata URL %s answered an empty identity token", url)
+	}
+	return token, nil
+}
@@ -2869,7 +2869,7 @@ const file_veilnet_proto_rawDesc = "" +
 	"QUERY_INFO\x10\a*!\n" +
 	"\x04Role\x12\f\n" +
 	"\bGUARDIAN\x10\x00\x12\v\n" +
-	"\aCONFLUX\x10\x012\xe7\x04\n" +
+	"\aCONFLUX\x10\x012\xf1\x05\n" +
 	"\x06Anchor\x12B\n" +
 	"\vStartAnchor\x12\x1b.veilnet.StartAnchorRequest\x1a\x16.google.protobuf.Empty\x12N\n" +
 	"\x11StartAnchorWithFD\x12!.veilnet.StartAnchorWithFDRequest\x1a\x16.google.protobuf.Empty\x12<\n" +
@@ -2880,7 +2880,9 @@ const file_veilnet_proto_rawDesc = "" +
 	"\aGetInfo\x12\x16.google.protobuf.Empty\x1a\x18.veilnet.GetInfoResponse\x12E\n" +
 	"\fGetRealmInfo\x12\x16.google.protobuf.Empty\x1a\x1d.veilnet.GetRealmInfoResponse\x12C\n" +
 	"\vGetVeilInfo\x12\x16.google.protobuf.Empty\x1a\x1c.veilnet.GetVeilInfoResponse\x12@\n" +
-	"\x0fGetTracerConfig\x12\x16.google.protobuf.Empty\x1a\x15.veilnet.TracerConfigB#Z!github.com/veil-net/veilnet/protob\x06proto3"
+	"\x0fGetTracerConfig\x12\x16.google.protobuf.Empty\x1a\x15.veilnet.TracerConfig\x12B\n" +
+	"\x10GetLocalNetworks\x12\x16.google.protobuf.Empty\x1a\x16.veilnet.LocalNetworks\x12D\n" +
+	"\x11GetRemoteNetworks\x12\x16.google.protobuf.Empty\x1a\x17.veilnet.RemoteNetworksB#Z!github.com/veil-net/veilnet/protob\x06proto3"
 
 var (
 	file_veilnet_proto_rawDescOnce sync.Once
@@ -2966,17 +2968,21 @@ var file_veilnet_proto_depIdxs = []int32{
 	47, // 16: veilnet.Anchor.GetRealmInfo:input_type -> google.protobuf.Empty
 	47, // 17: veilnet.Anchor.GetVeilInfo:input_type -> google.protobuf.Empty
 	47, // 18: veilnet.Anchor.GetTracerConfig:input_type -> google.protobuf.Empty
-	47, // 19: veilnet.Anchor.StartAnchor:output_type -> google.protobuf.Empty
-	47, // 20: veilnet.Anchor.StartAnchorWithFD:output_type -> google.protobuf.Empty
-	47, // 21: veilnet.Anchor.StopAnchor:output_type -> google.protobuf.Empty
-	47, // 22: veilnet.Anchor.AddTaint:output_type -> google.protobuf.Empty
-	47, // 23: veilnet.Anchor.RemoveTaint:output_type -> google.protobuf.Empty
-	44, // 24: veilnet.Anchor.GetInfo:output_type -> veilnet.GetInfoResponse
-	45, // 25: veilnet.Anchor.GetRealmInfo:output_type -> veilnet.GetRealmInfoResponse
-	46, // 26: veilnet.Anchor.GetVeilInfo:output_type -> veilnet.GetVeilInfoResponse
-	39, // 27: veilnet.Anchor.GetTracerConfig:output_type -> veilnet.TracerConfig
-	19, // [19:28] is the sub-list for method output_type
-	10, // [10:19] is the sub-list for method input_type
+	47, // 19: veilnet.Anchor.GetLocalNetworks:input_type -> google.protobuf.Empty
+	47, // 20: veilnet.Anchor.GetRemoteNetworks:input_type -> google.protobuf.Empty
+	47, // 21: veilnet.Anchor.StartAnchor:output_type -> google.protobuf.Empty
+	47, // 22: veilnet.Anchor.StartAnchorWithFD:output_type -> google.protobuf.Empty
+	47, // 23: veilnet.Anchor.StopAnchor:output_type -> google.protobuf.Empty
+	47, // 24: veilnet.Anchor.AddTaint:output_type -> google.protobuf.Empty
+	47, // 25: veilnet.Anchor.RemoveTaint:output_type -> google.protobuf.Empty
+	44, // 26: veilnet.Anchor.GetInfo:output_type -> veilnet.GetInfoResponse
+	45, // 27: veilnet.Anchor.GetRealmInfo:output_type -> veilnet.GetRealmInfoResponse
+	46, // 28: veilnet.Anchor.GetVeilInfo:output_type -> veilnet.GetVeilInfoResponse
+	39, // 29: veilnet.Anchor.GetTracerConfig:output_type -> veilnet.TracerConfig
+	25, // 30: veilnet.Anchor.GetLocalNetworks:output_type -> veilnet.LocalNetworks
+	27, // 31: veilnet.Anchor.GetRemoteNetworks:output_type -> veilnet.RemoteNetworks
+	21, // [21:32] is the sub-list for method output_type
+	10, // [10:21] is the sub-list for method input_type
 	10, // [10:10] is the sub-list for extension type_name
 	10, // [10:10] is the sub-list for extension extendee
 	0,  // [0:10] is the sub-list for field type_name
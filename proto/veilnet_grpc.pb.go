@@ -29,6 +29,8 @@ const (
 	Anchor_GetRealmInfo_FullMethodName      = "/veilnet.Anchor/GetRealmInfo"
 	Anchor_GetVeilInfo_FullMethodName       = "/veilnet.Anchor/GetVeilInfo"
 	Anchor_GetTracerConfig_FullMethodName   = "/veilnet.Anchor/GetTracerConfig"
+	Anchor_GetLocalNetworks_FullMethodName  = "/veilnet.Anchor/GetLocalNetworks"
+	Anchor_GetRemoteNetworks_FullMethodName = "/veilnet.Anchor/GetRemoteNetworks"
 )
 
 // AnchorClient is the client API for Anchor service.
@@ -44,6 +46,8 @@ type AnchorClient interface {
 	GetRealmInfo(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetRealmInfoResponse, error)
 	GetVeilInfo(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetVeilInfoResponse, error)
 	GetTracerConfig(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*TracerConfig, error)
+	GetLocalNetworks(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LocalNetworks, error)
+	GetRemoteNetworks(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*RemoteNetworks, error)
 }
 
 type anchorClient struct {
@@ -144,6 +148,26 @@ func (c *anchorClient) GetTracerConfig(ctx context.Context, in *emptypb.Empty, o
 	return out, nil
 }
 
+func (c *anchorClient) GetLocalNetworks(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LocalNetworks, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LocalNetworks)
+	err := c.cc.Invoke(ctx, Anchor_GetLocalNetworks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *anchorClient) GetRemoteNetworks(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*RemoteNetworks, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoteNetworks)
+	err := c.cc.Invoke(ctx, Anchor_GetRemoteNetworks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AnchorServer is the server API for Anchor service.
 // All implementations must embed UnimplementedAnchorServer
 // for forward compatibility.
@@ -157,6 +181,8 @@ type AnchorServer interface {
 	GetRealmInfo(context.Context, *emptypb.Empty) (*GetRealmInfoResponse, error)
 	GetVeilInfo(context.Context, *emptypb.Empty) (*GetVeilInfoResponse, error)
 	GetTracerConfig(context.Context, *emptypb.Empty) (*TracerConfig, error)
+	GetLocalNetworks(context.Context, *emptypb.Empty) (*LocalNetworks, error)
+	GetRemoteNetworks(context.Context, *emptypb.Empty) (*RemoteNetworks, error)
 	mustEmbedUnimplementedAnchorServer()
 }
 
@@ -194,6 +220,12 @@ func (UnimplementedAnchorServer) GetVeilInfo(context.Context, *emptypb.Empty) (*
 func (UnimplementedAnchorServer) GetTracerConfig(context.Context, *emptypb.Empty) (*TracerConfig, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTracerConfig not implemented")
 }
+func (UnimplementedAnchorServer) GetLocalNetworks(context.Context, *emptypb.Empty) (*LocalNetworks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLocalNetworks not implemented")
+}
+func (UnimplementedAnchorServer) GetRemoteNetworks(context.Context, *emptypb.Empty) (*RemoteNetworks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRemoteNetworks not implemented")
+}
 func (UnimplementedAnchorServer) mustEmbedUnimplementedAnchorServer() {}
 func (UnimplementedAnchorServer) testEmbeddedByValue()                {}
 
@@ -377,6 +409,42 @@ func _Anchor_GetTracerConfig_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Anchor_GetLocalNetworks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnchorServer).GetLocalNetworks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Anchor_GetLocalNetworks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnchorServer).GetLocalNetworks(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Anchor_GetRemoteNetworks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnchorServer).GetRemoteNetworks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Anchor_GetRemoteNetworks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnchorServer).GetRemoteNetworks(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Anchor_ServiceDesc is the grpc.ServiceDesc for Anchor service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -420,6 +488,14 @@ var Anchor_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTracerConfig",
 			Handler:    _Anchor_GetTracerConfig_Handler,
 		},
+		{
+			MethodName: "GetLocalNetworks",
+			Handler:    _Anchor_GetLocalNetworks_Handler,
+		},
+		{
+			MethodName: "GetRemoteNetworks",
+			Handler:    _Anchor_GetRemoteNetworks_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "veilnet.proto",
@@ -0,0 +1,155 @@
+// Package secret resolves secret references such as file:/run/secrets/token, env:NAME or exec:helper args, so
+// credentials do not have to appear on the command line or in plain environment variables.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veil-net/conflux/logger"
+)
+
+// Logger re-exports the global logger for the secret package.
+var Logger = logger.Logger
+
+// execTimeout bounds a helper command run by an exec: reference when the context has no deadline.
+const execTimeout = 30 * time.Second
+
+// Source fetches secrets for the references of one scheme.
+type Source interface {
+	// Fetch returns the secret named by ref, the part of the reference after "scheme:".
+	Fetch(ctx context.Context, ref string) (string, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context, ref string) (string, error)
+
+// Fetch calls f.
+func (f SourceFunc) Fetch(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = map[string]Source{
+		"file": SourceFunc(fetchFile),
+		"env":  SourceFunc(fetchEnv),
+		"exec": SourceFunc(fetchExec),
+	}
+)
+
+// Register adds or replaces the source of a scheme, e.g. to fetch "vault:path" references in-process.
+//
+// Inputs:
+//   - scheme: string. The scheme without the colon.
+//   - source: Source. The source fetching the references of the scheme.
+//
+// Outputs: none.
+func Register(scheme string, source Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources[scheme] = source
+}
+
+// lookup returns the source and reference of value, or false if value is not a reference to a registered scheme.
+func lookup(value string) (Source, string, bool) {
+	scheme, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, "", false
+	}
+	sourcesMu.RLock()
+	defer sourcesMu.RUnlock()
+	source, ok := sources[scheme]
+	return source, ref, ok
+}
+
+// IsReference reports whether value is a reference to a registered scheme rather than a literal secret.
+//
+// Inputs:
+//   - value: string. The value to check.
+//
+// Outputs:
+//   - bool. True if Resolve would fetch the value from a source.
+func IsReference(value string) bool {
+	_, _, ok := lookup(value)
+	return ok
+}
+
+// Resolve fetches value from its source if it is a reference, and returns it unchanged otherwise.
+// Fetched secrets have surrounding whitespace, such as the trailing newline of secret files, removed.
+//
+// Inputs:
+//   - ctx: context.Context. Bounds the fetch.
+//   - value: string. A literal secret or a scheme:ref reference.
+//
+// Outputs:
+//   - string. The secret.
+//   - err: error. Non-nil if the source fails or returns an empty secret.
+func Resolve(ctx context.Context, value string) (string, error) {
+	source, ref, ok := lookup(value)
+	if !ok {
+		return value, nil
+	}
+	scheme, _, _ := strings.Cut(value, ":")
+	secret, err := source.Fetch(ctx, ref)
+	if err != nil {
+		Logger.Sugar().Errorf("failed to fetch %s secret: %v", scheme, err)
+		return "", fmt.Errorf("failed to fetch %s secret: %w", scheme, err)
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("%s secret %q is empty", scheme, ref)
+	}
+	return secret, nil
+}
+
+// fetchFile reads the secret from a file, e.g. a Docker or Kubernetes secret mount.
+func fetchFile(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// fetchEnv reads the secret from an environment variable.
+func fetchEnv(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fetchExec runs a helper command and reads the secret from its standard output. The command is split on spaces
+// without shell quoting; wrap complex invocations in a script.
+func fetchExec(ctx context.Context, command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("no command given")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, execTimeout)
+		defer cancel()
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return "", fmt.Errorf("%s: %w: %s", args[0], err, detail)
+		}
+		return "", fmt.Errorf("%s: %w", args[0], err)
+	}
+	return string(out), nil
+}
@@ -3,16 +3,32 @@ package service
 import (
 	"context"
 	"os"
+	"os/exec"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/metrics"
 	pb "github.com/veil-net/conflux/proto"
+	"github.com/veil-net/conflux/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc"
 )
 
+// anchorRestartDelay is how long the service waits before restarting an anchor that exited unexpectedly.
+const anchorRestartDelay = 5 * time.Second
+
+// anchorRestartMaxDelay caps the delay between restarts when the anchor keeps failing to start; the delay doubles
+// from anchorRestartDelay after each failure.
+const anchorRestartMaxDelay = 5 * time.Minute
+
 // ServiceImpl is the concrete implementation that runs the anchor (load config, start subprocess, gRPC client, handle signals).
 type ServiceImpl struct {
+	// newAnchor starts the anchor subprocess; platforms may replace it to add a fallback.
+	newAnchor func() (*exec.Cmd, error)
+	// tunFD is the descriptor of the TUN passed to the anchor subprocess, 0 if the anchor creates its own.
+	tunFD int32
 }
 
 // NewServiceImpl returns a new ServiceImpl.
@@ -22,7 +38,23 @@ type ServiceImpl struct {
 // Outputs:
 //   - *ServiceImpl. A new ServiceImpl.
 func NewServiceImpl() *ServiceImpl {
-	return &ServiceImpl{}
+	return &ServiceImpl{
+		newAnchor: anchor.NewAnchor,
+	}
+}
+
+// UseTUN makes the anchor use tun instead of creating its TUN; each anchor subprocess inherits it.
+//
+// Inputs:
+//   - s: *ServiceImpl. The implementation.
+//   - tun: *os.File. The TUN, e.g. from anchor.OpenTUN.
+//
+// Outputs: none.
+func (s *ServiceImpl) UseTUN(tun *os.File) {
+	s.newAnchor = func() (*exec.Cmd, error) {
+		return anchor.NewAnchorWithTUN(tun)
+	}
+	s.tunFD = anchor.TUNFileDescriptor
 }
 
 // Run runs the anchor in the foreground until interrupt (loads config, starts subprocess and gRPC client, handles signals).
@@ -30,78 +62,218 @@ func NewServiceImpl() *ServiceImpl {
 // Inputs:
 //   - s: *ServiceImpl. The implementation; uses config from the default config file.
 //
-// Outputs: none. Does not return; runs until process interrupt (SIGINT/SIGTERM).
+// Outputs: none. Does not return until process interrupt (SIGINT/SIGTERM) or a fatal error.
 func (s *ServiceImpl) Run() {
+	// Stop on interrupt signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s.Serve(ctx, nil)
+}
 
+// Serve runs the anchor from the config file until ctx is cancelled, restarting the subprocess whenever it
+// exits unexpectedly. The config file is reloaded before each restart.
+//
+// Inputs:
+//   - s: *ServiceImpl. The implementation; uses config from the default config file.
+//   - ctx: context.Context. Cancel to stop the anchor and return.
+//   - ready: func(). Optional; called once after the anchor first started successfully.
+//
+// Outputs:
+//   - err: error. Non-nil if the config cannot be loaded or the anchor cannot be started.
+func (s *ServiceImpl) Serve(ctx context.Context, ready func()) error {
 	// Load the configuration
 	config, err := anchor.LoadConfig()
 	if err != nil {
 		Logger.Sugar().Errorf("failed to load configuration: %v", err)
-		return
+		return err
+	}
+	return s.serve(ctx, config, true, ready)
+}
+
+// ServeConfig runs the anchor with the given config until ctx is cancelled, restarting the subprocess whenever
+// it exits unexpectedly. Used by debug mode, where the config may not be saved.
+//
+// Inputs:
+//   - s: *ServiceImpl. The implementation.
+//   - ctx: context.Context. Cancel to stop the anchor and return.
+//   - config: *anchor.ConfluxConfig. The conflux config.
+//   - ready: func(). Optional; called once after the anchor first started successfully.
+//
+// Outputs:
+//   - err: error. Non-nil if the anchor cannot be started.
+func (s *ServiceImpl) ServeConfig(ctx context.Context, config *anchor.ConfluxConfig, ready func()) error {
+	return s.serve(ctx, config, false, ready)
+}
+
+// serve implements Serve and ServeConfig; reload selects whether the config file is reloaded before a restart.
+func (s *ServiceImpl) serve(ctx context.Context, config *anchor.ConfluxConfig, reload bool, ready func()) error {
+	// Export the wrapper's own telemetry with the tracer settings
+	shutdown, err := telemetry.Setup(ctx, config.Tracer.Telemetry())
+	if err != nil {
+		Logger.Sugar().Warnf("failed to set up telemetry, continuing without it: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown(ctx)
+	}()
+
+	// Start the HTTP monitor if configured
+	var monitor *Monitor
+	if config.HTTPAddr != "" {
+		monitor = NewMonitor(config.HTTPAddr)
+		if err := monitor.Start(); err != nil {
+			Logger.Sugar().Warnf("failed to start HTTP monitor on %s: %v", config.HTTPAddr, err)
+			monitor = nil
+		} else {
+			defer monitor.Stop()
+		}
 	}
 
+	// Count the registration the config was written by, the register command ran in another process
+	if config.Registered {
+		metrics.RecordRegistration(nil)
+		config.Registered = false
+		if reload {
+			if err := anchor.ClearRegistered(); err != nil {
+				Logger.Sugar().Warnf("failed to clear the registration in the configuration, a restart counts it again: %v", err)
+			}
+		}
+	}
+
+	if s.tunFD > 0 {
+		defer anchor.SaveTUNFD(0)
+	}
+	var restartDelay time.Duration
+	started := false
+	for {
+		// Wait before a restart, then reload the configuration in case it changed while the anchor was down
+		if restartDelay > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(restartDelay):
+			}
+			if reload {
+				if reloaded, err := anchor.LoadConfig(); err == nil {
+					config = reloaded
+				} else {
+					Logger.Sugar().Warnf("failed to reload configuration, reusing the previous one: %v", err)
+				}
+			}
+		}
+
+		// Record the TUN of the anchor for the commands restarting it from another process
+		config.TUNFD = s.tunFD
+		if err := anchor.SaveTUNFD(s.tunFD); err != nil {
+			Logger.Sugar().Warnf("failed to record the TUN of the anchor, restarting it from the command line may not keep it: %v", err)
+		}
+		subprocess, conn, err := s.startAnchor(ctx, config)
+		if err != nil {
+			// Only the first start is fatal, a restart after a crash is retried with backoff
+			if !started {
+				return err
+			}
+			restartDelay = min(max(2*restartDelay, anchorRestartDelay), anchorRestartMaxDelay)
+			Logger.Sugar().Errorf("failed to restart anchor, retrying in %s: %v", restartDelay, err)
+			continue
+		}
+		started = true
+		if monitor != nil {
+			monitor.SetClient(pb.NewAnchorClient(conn))
+		}
+		if ready != nil {
+			ready()
+			ready = nil
+		}
+
+		// Wait for cancellation or an unexpected anchor exit
+		exited := anchor.WaitAnchor(subprocess)
+		select {
+		case <-ctx.Done():
+			subprocess.Process.Kill()
+			<-exited
+			conn.Close()
+			return nil
+		case err := <-exited:
+			Logger.Sugar().Errorf("%s", anchor.CrashReport(err))
+		}
+		if monitor != nil {
+			monitor.SetClient(nil)
+		}
+		conn.Close()
+
+		// Restart the anchor after a delay
+		metrics.AnchorRestarts.Inc()
+		restartDelay = anchorRestartDelay
+		Logger.Sugar().Infof("restarting anchor in %s", restartDelay)
+	}
+}
+
+// startAnchor starts the anchor subprocess, connects the gRPC client, starts the anchor and applies the taints.
+// Each phase is traced and measured.
+//
+// Inputs:
+//   - s: *ServiceImpl. The implementation.
+//   - ctx: context.Context. The parent context of the startup span and RPCs.
+//   - config: *anchor.ConfluxConfig. The conflux config.
+//
+// Outputs:
+//   - *exec.Cmd. The running anchor subprocess.
+//   - *grpc.ClientConn. The gRPC connection to it; the caller closes it once the subprocess exits.
+//   - err: error. Non-nil if any step fails; the subprocess is killed and the connection closed in that case.
+func (s *ServiceImpl) startAnchor(ctx context.Context, config *anchor.ConfluxConfig) (subprocess *exec.Cmd, conn *grpc.ClientConn, err error) {
+	ctx, end := telemetry.StartPhase(ctx, "anchor.startup", attribute.String("conflux_id", config.ConfluxID))
+	defer func() { end(err) }()
+
 	// Initialize the anchor plugin
-	subprocess, err := anchor.NewAnchor()
+	_, endSpawn := telemetry.StartPhase(ctx, "anchor.spawn")
+	subprocess, err = s.newAnchor()
+	endSpawn(err)
 	if err != nil {
 		Logger.Sugar().Errorf("failed to initialize anchor subprocess: %v", err)
-		return
+		return nil, nil, err
 	}
-	defer subprocess.Process.Kill()
 
-	// Wait for the subprocess to start
+	// Wait for the subprocess to start and create a gRPC client connection
+	_, endReady := telemetry.StartPhase(ctx, "anchor.ready")
 	time.Sleep(1 * time.Second)
-
-	// Create a gRPC client connection
-	anchor, err := anchor.NewAnchorClient()
+	conn, err = anchor.DialAnchor()
+	endReady(err)
 	if err != nil {
+		subprocess.Process.Kill()
 		Logger.Sugar().Errorf("failed to create anchor gRPC client: %v", err)
-		return
-	}
-
-	var tracerConfig *pb.TracerConfig
-	if config.Tracer != nil {
-		tracerConfig = &pb.TracerConfig{
-			Enabled:  config.Tracer.Enabled,
-			Endpoint: config.Tracer.Endpoint,
-			UseTls:   config.Tracer.UseTLS,
-			Insecure: config.Tracer.Insecure,
-			Ca:       config.Tracer.CAFile,
-			Cert:     config.Tracer.CertFile,
-			Key:      config.Tracer.KeyFile,
-		}
+		return nil, nil, err
 	}
+	client := pb.NewAnchorClient(conn)
 
 	// Start the anchor
-	_, err = anchor.StartAnchor(context.Background(), &pb.StartAnchorRequest{
-		GuardianUrl: config.Guardian,
-		AnchorToken: config.Token,
-		Ip:          config.IP,
-		Rift:        config.Rift,
-		Portal:      config.Portal,
-		Conduit:     config.Conduit,
-		Tracer:     tracerConfig,
-	})
+	startCtx, endStart := telemetry.StartPhase(ctx, "anchor.start")
+	err = anchor.StartAnchor(startCtx, client, config)
+	endStart(err)
 	if err != nil {
+		subprocess.Process.Kill()
+		conn.Close()
 		Logger.Sugar().Errorf("failed to start anchor: %v", err)
-		return
+		return nil, nil, err
 	}
 
 	// Add taints
+	taintCtx, endTaints := telemetry.StartPhase(ctx, "anchor.taints", attribute.Int("taints", len(config.Taints)))
 	for _, taint := range config.Taints {
-		_, err = anchor.AddTaint(context.Background(), &pb.AddTaintRequest{
+		_, err = client.AddTaint(taintCtx, &pb.AddTaintRequest{
 			Taint: taint,
 		})
 		if err != nil {
+			endTaints(err)
+			subprocess.Process.Kill()
+			conn.Close()
 			Logger.Sugar().Errorf("failed to add taint: %v", err)
-			return
+			return nil, nil, err
 		}
 	}
+	endTaints(nil)
 
-	// Wait for interrupt signal
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
-
-	// Wait for interrupt signal
-	<-interrupt
-
+	return subprocess, conn, nil
 }
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/anchortest"
+)
+
+// helperAnchorEnv makes the test binary idle in place of the anchor subprocess, which the fake anchor stands in for.
+const helperAnchorEnv = "CONFLUX_TEST_HELPER_ANCHOR"
+
+// TestMain runs the helper anchor when asked to, and otherwise points the config directory at a temporary
+// directory, so tests never touch the config of the host.
+func TestMain(m *testing.M) {
+	if os.Getenv(helperAnchorEnv) == "1" {
+		time.Sleep(time.Hour)
+		os.Exit(0)
+	}
+	dir, err := os.MkdirTemp("", "conflux-service-test")
+	if err != nil {
+		panic(err)
+	}
+	anchor.SetConfigDir(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// freeAddr returns a loopback address with a port nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestServeCountsRegistration(t *testing.T) {
+	t.Setenv(anchor.CredentialStoreEnv, anchor.CredentialStorePlaintext)
+	server := anchortest.NewServer()
+	address, err := server.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	t.Setenv(anchor.AnchorAddressEnv, address)
+
+	// The register command wrote the config of a new registration
+	httpAddr := freeAddr(t)
+	config := &anchor.ConfluxConfig{ConfluxID: "conflux-1", Token: "token-1", Guardian: "https://guardian.example.com", HTTPAddr: httpAddr, Registered: true}
+	if err := anchor.SaveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	impl := &ServiceImpl{newAnchor: func() (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^$")
+		cmd.Env = append(os.Environ(), helperAnchorEnv+"=1")
+		return cmd, cmd.Start()
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan struct{})
+	served := make(chan error, 1)
+	go func() { served <- impl.Serve(ctx, func() { close(ready) }) }()
+	t.Cleanup(func() {
+		cancel()
+		<-served
+	})
+	select {
+	case <-ready:
+	case err := <-served:
+		t.Fatalf("Serve returned before the anchor started: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the anchor did not start")
+	}
+
+	resp, err := http.Get("http://" + httpAddr + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `conflux_registrations_total{outcome="success"} 1`; !strings.Contains(string(body), want) {
+		t.Errorf("/metrics does not contain %s", want)
+	}
+
+	// The registration is counted once
+	saved, err := anchor.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Registered {
+		t.Error("the registration is still pending in conflux.json")
+	}
+	if saved.Token != "token-1" {
+		t.Errorf("token = %q, want it kept", saved.Token)
+	}
+}
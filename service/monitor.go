@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/veil-net/conflux/anchor"
+	"github.com/veil-net/conflux/metrics"
+	pb "github.com/veil-net/conflux/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// Polling settings for the HTTP monitor.
+const (
+	monitorPollInterval = 15 * time.Second
+	monitorPollTimeout  = 5 * time.Second
+)
+
+// Monitor serves the HTTP endpoints of the running conflux (/metrics, /healthz, /readyz) and polls the anchor
+// to keep the metrics current.
+type Monitor struct {
+	addr   string
+	server *http.Server
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	client pb.AnchorClient
+}
+
+// NewMonitor returns a monitor that will listen on addr.
+//
+// Inputs:
+//   - addr: string. The listen address, e.g. "127.0.0.1:9193" or ":9193".
+//
+// Outputs:
+//   - *Monitor. A monitor that is not started yet.
+func NewMonitor(addr string) *Monitor {
+	m := &Monitor{addr: addr}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", m.handleHealthz)
+	mux.HandleFunc("/readyz", m.handleReadyz)
+	m.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return m
+}
+
+// Start binds the listen address, then serves HTTP and polls the anchor in the background.
+//
+// Inputs:
+//   - m: *Monitor. The monitor.
+//
+// Outputs:
+//   - err: error. Non-nil if the address cannot be bound.
+func (m *Monitor) Start() error {
+	listener, err := net.Listen("tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	go func() {
+		err := m.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Logger.Sugar().Errorf("HTTP monitor stopped: %v", err)
+		}
+	}()
+	go m.pollLoop(ctx)
+
+	Logger.Sugar().Infof("HTTP monitor listening on %s", listener.Addr())
+	return nil
+}
+
+// Stop stops polling and shuts the HTTP server down.
+//
+// Inputs:
+//   - m: *Monitor. The monitor.
+//
+// Outputs: none.
+func (m *Monitor) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m.server.Shutdown(ctx)
+}
+
+// SetClient sets the anchor client to poll; nil marks the anchor as down.
+//
+// Inputs:
+//   - m: *Monitor. The monitor.
+//   - client: pb.AnchorClient. The client of the running anchor, or nil while it is not running.
+//
+// Outputs: none.
+func (m *Monitor) SetClient(client pb.AnchorClient) {
+	m.mu.Lock()
+	m.client = client
+	m.mu.Unlock()
+	if client == nil {
+		metrics.SetAnchorDown()
+	}
+}
+
+// getClient returns the current anchor client.
+func (m *Monitor) getClient() pb.AnchorClient {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.client
+}
+
+// pollLoop polls the anchor every monitorPollInterval until ctx is cancelled.
+func (m *Monitor) pollLoop(ctx context.Context) {
+	defer close(m.done)
+	ticker := time.NewTicker(monitorPollInterval)
+	defer ticker.Stop()
+	for {
+		m.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads info, realm and veil from the anchor and updates the metrics.
+func (m *Monitor) poll(ctx context.Context) {
+	client := m.getClient()
+	if client == nil {
+		metrics.SetAnchorDown()
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, monitorPollTimeout)
+	defer cancel()
+
+	info, err := client.GetInfo(ctx, &emptypb.Empty{})
+	if err != nil {
+		metrics.SetAnchorDown()
+		return
+	}
+	realm, err := client.GetRealmInfo(ctx, &emptypb.Empty{})
+	if err != nil {
+		metrics.SetAnchorDown()
+		return
+	}
+	veil, err := client.GetVeilInfo(ctx, &emptypb.Empty{})
+	if err != nil {
+		metrics.SetAnchorDown()
+		return
+	}
+	metrics.SetAnchorState(info, realm, veil)
+}
+
+// handleHealthz is the liveness probe: 200 when the anchor is reachable and answers GetInfo, 503 otherwise.
+func (m *Monitor) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), monitorPollTimeout)
+	defer cancel()
+	writeHealthReport(w, anchor.CheckLiveness(ctx, m.getClient()))
+}
+
+// handleReadyz is the readiness probe: 200 when every health check passes, 503 otherwise.
+// The optional min_tethers query parameter sets the tether threshold.
+func (m *Monitor) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	minTethers := 0
+	if value := r.URL.Query().Get("min_tethers"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "invalid min_tethers", http.StatusBadRequest)
+			return
+		}
+		minTethers = parsed
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), monitorPollTimeout)
+	defer cancel()
+	writeHealthReport(w, anchor.CheckHealth(ctx, m.getClient(), minTethers))
+}
+
+// writeHealthReport writes report as JSON with status 200 if healthy and 503 otherwise.
+func writeHealthReport(w http.ResponseWriter, report *anchor.HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
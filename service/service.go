@@ -12,7 +12,7 @@ import (
 // Logger re-exports the global logger for the service package.
 var Logger = logger.Logger
 
-// Service is the interface for running and managing the conflux service (Run, Install, Start, Stop, Remove, Status).
+// Service is the interface for running and managing the conflux service (Run, Install, Start, Stop, Remove, Status, State, Unit).
 type Service interface {
 	Run() error
 	Install() error
@@ -20,6 +20,17 @@ type Service interface {
 	Stop() error
 	Remove() error
 	Status() error
+	State() (*State, error)
+	Unit() (string, error)
+}
+
+// State is the state of the installed conflux service as reported by the service manager.
+type State struct {
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+	Running   bool   `json:"running"`
+	Status    string `json:"status"`
+	PID       int    `json:"pid"`
 }
 
 // NewService returns the platform-specific Service implementation.
@@ -5,11 +5,18 @@ package service
 
 import (
 	"bytes"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"text/template"
 )
 
+// LaunchDaemonLogFile is the rotated log file the LaunchDaemon writes to; stderr only receives crash output.
+const LaunchDaemonLogFile = "/var/log/veilnet-conflux.log"
+
 // LaunchDaemonPlistTemplate is the LaunchDaemon plist template for the conflux service.
 const LaunchDaemonPlistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
@@ -20,19 +27,23 @@ const LaunchDaemonPlistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
 	<key>ProgramArguments</key>
 	<array>
 		<string>{{.ExecPath}}</string>
+		<string>run</string>
+		<string>--log-file</string>
+		<string>{{.LogFile}}</string>
 	</array>
 	<key>RunAtLoad</key>
 	<true/>
 	<key>KeepAlive</key>
 	<true/>
-	<key>StandardOutPath</key>
-	<string>/var/log/veilnet-conflux.log</string>
 	<key>StandardErrorPath</key>
 	<string>/var/log/veilnet-conflux.error.log</string>
 </dict>
 </plist>
 `
 
+// launchctlPID matches the PID entry of `launchctl list <label>` output.
+var launchctlPID = regexp.MustCompile(`"PID"\s*=\s*(\d+);`)
+
 // service is the Darwin implementation holding the ServiceImpl.
 type service struct {
 	serviceImpl *ServiceImpl
@@ -90,7 +101,11 @@ func (s *service) Install() error {
 	}
 
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, struct{ ExecPath string }{ExecPath: realPath}); err != nil {
+	data := struct {
+		ExecPath string
+		LogFile  string
+	}{ExecPath: realPath, LogFile: LaunchDaemonLogFile}
+	if err := tmpl.Execute(&buf, data); err != nil {
 		Logger.Sugar().Errorf("failed to execute launchdaemon template: %v", err)
 		return err
 	}
@@ -184,3 +199,52 @@ func (s *service) Status() error {
 	Logger.Sugar().Infof("VeilNet Conflux service status: running")
 	return nil
 }
+
+// State reads the conflux service state via launchctl list and the plist file.
+//
+// Inputs:
+//   - s: *service. The Darwin service.
+//
+// Outputs:
+//   - *State. The service state; Installed is true if the plist file exists.
+//   - err: error. Non-nil if launchctl fails for another reason than the job not being loaded.
+func (s *service) State() (*State, error) {
+	state := &State{Name: "org.veilnet.conflux", Status: "not loaded"}
+	if _, err := os.Stat("/Library/LaunchDaemons/org.veilnet.conflux.plist"); err == nil {
+		state.Installed = true
+	}
+
+	// launchctl list exits non-zero when the job is not loaded
+	out, err := exec.Command("launchctl", "list", "org.veilnet.conflux").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return state, nil
+		}
+		Logger.Sugar().Errorf("failed to query service state: %v", err)
+		return nil, err
+	}
+	state.Status = "loaded"
+	if match := launchctlPID.FindSubmatch(out); match != nil {
+		state.PID, _ = strconv.Atoi(string(match[1]))
+		state.Running = true
+		state.Status = "running"
+	}
+	return state, nil
+}
+
+// Unit returns the installed LaunchDaemon plist.
+//
+// Inputs:
+//   - s: *service. The Darwin service.
+//
+// Outputs:
+//   - string. The content of /Library/LaunchDaemons/org.veilnet.conflux.plist.
+//   - err: error. Non-nil if the plist cannot be read (e.g. the service is not installed).
+func (s *service) Unit() (string, error) {
+	plist, err := os.ReadFile("/Library/LaunchDaemons/org.veilnet.conflux.plist")
+	if err != nil {
+		return "", err
+	}
+	return string(plist), nil
+}
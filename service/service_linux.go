@@ -5,8 +5,12 @@ package service
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"text/template"
 )
 
@@ -202,3 +206,49 @@ func (s *service) Status() error {
 	}
 	return nil
 }
+
+// State reads the conflux service state via systemctl show.
+//
+// Inputs:
+//   - s: *service. The Linux service.
+//
+// Outputs:
+//   - *State. The service state; Installed is false if the unit is not loaded.
+//   - err: error. Non-nil if systemctl fails.
+func (s *service) State() (*State, error) {
+	out, err := exec.Command("systemctl", "show", "veilnet", "--property=LoadState,ActiveState,SubState,MainPID").Output()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to query service state: %v", err)
+		return nil, err
+	}
+	properties := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			properties[key] = value
+		}
+	}
+	pid, _ := strconv.Atoi(properties["MainPID"])
+	return &State{
+		Name:      "veilnet",
+		Installed: properties["LoadState"] == "loaded",
+		Running:   properties["ActiveState"] == "active",
+		Status:    fmt.Sprintf("%s (%s)", properties["ActiveState"], properties["SubState"]),
+		PID:       pid,
+	}, nil
+}
+
+// Unit returns the installed systemd unit file.
+//
+// Inputs:
+//   - s: *service. The Linux service.
+//
+// Outputs:
+//   - string. The content of /etc/systemd/system/veilnet.service.
+//   - err: error. Non-nil if the unit file cannot be read (e.g. the service is not installed).
+func (s *service) Unit() (string, error) {
+	unit, err := os.ReadFile("/etc/systemd/system/veilnet.service")
+	if err != nil {
+		return "", err
+	}
+	return string(unit), nil
+}
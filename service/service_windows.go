@@ -5,14 +5,13 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/veil-net/conflux/anchor"
-	pb "github.com/veil-net/conflux/proto"
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/eventlog"
 	"golang.org/x/sys/windows/svc/mgr"
@@ -53,6 +52,7 @@ func removeEventSource() error {
 // newService returns the Windows-specific service.
 func newService() *service {
 	serviceImpl := NewServiceImpl()
+	serviceImpl.newAnchor = newAnchorWithFallback
 	return &service{
 		serviceImpl: serviceImpl,
 	}
@@ -289,6 +289,94 @@ func (s *service) Status() error {
 	return nil
 }
 
+// windowsStates names the SCM service states.
+var windowsStates = map[svc.State]string{
+	svc.Stopped:         "stopped",
+	svc.StartPending:    "start pending",
+	svc.StopPending:     "stop pending",
+	svc.Running:         "running",
+	svc.ContinuePending: "continue pending",
+	svc.PausePending:    "pause pending",
+	svc.Paused:          "paused",
+}
+
+// State reads the conflux service state from the service manager.
+//
+// Inputs:
+//   - s: *service. The Windows service.
+//
+// Outputs:
+//   - *State. The service state; Installed is false if the service does not exist.
+//   - err: error. Non-nil if the service manager cannot be queried.
+func (s *service) State() (*State, error) {
+	// Connect to the service manager
+	m, err := mgr.Connect()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to connect to service manager: %v", err)
+		return nil, err
+	}
+	defer m.Disconnect()
+
+	// Open the service
+	state := &State{Name: windowsServiceName, Status: "not installed"}
+	service, err := m.OpenService(windowsServiceName)
+	if err != nil {
+		return state, nil
+	}
+	defer service.Close()
+	state.Installed = true
+
+	// Get the service status
+	status, err := service.Query()
+	if err != nil {
+		Logger.Sugar().Errorf("failed to query service: %v", err)
+		return nil, err
+	}
+	state.Running = status.State == svc.Running
+	state.Status = windowsStates[status.State]
+	state.PID = int(status.ProcessId)
+	return state, nil
+}
+
+// Unit returns the service configuration registered with the service manager.
+//
+// Inputs:
+//   - s: *service. The Windows service.
+//
+// Outputs:
+//   - string. The service configuration, one "key: value" per line.
+//   - err: error. Non-nil if the service manager cannot be queried or the service is not installed.
+func (s *service) Unit() (string, error) {
+	// Connect to the service manager
+	m, err := mgr.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer m.Disconnect()
+
+	// Open the service
+	service, err := m.OpenService(windowsServiceName)
+	if err != nil {
+		return "", err
+	}
+	defer service.Close()
+
+	// Get the service configuration
+	config, err := service.Config()
+	if err != nil {
+		return "", err
+	}
+	var unit strings.Builder
+	fmt.Fprintf(&unit, "ServiceName: %s\n", windowsServiceName)
+	fmt.Fprintf(&unit, "DisplayName: %s\n", config.DisplayName)
+	fmt.Fprintf(&unit, "Description: %s\n", config.Description)
+	fmt.Fprintf(&unit, "BinaryPathName: %s\n", config.BinaryPathName)
+	fmt.Fprintf(&unit, "StartType: %d\n", config.StartType)
+	fmt.Fprintf(&unit, "ServiceStartName: %s\n", config.ServiceStartName)
+	fmt.Fprintf(&unit, "Dependencies: %s\n", strings.Join(config.Dependencies, ", "))
+	return unit.String(), nil
+}
+
 // Execute implements the Windows service handler: StartPending, start anchor, Running, then handle Stop, Shutdown, and Interrogate.
 //
 // Inputs:
@@ -299,7 +387,7 @@ func (s *service) Status() error {
 //
 // Outputs:
 //   - ssec: bool. As required by the svc package.
-//   - errno: uint32. As required by the svc package; 0 when the service stops.
+//   - errno: uint32. As required by the svc package; 0 when the service stops, 1 if the anchor fails.
 func (s *service) Execute(args []string, changeRequests <-chan svc.ChangeRequest, changes chan<- svc.Status) (ssec bool, errno uint32) {
 	elog, elogErr := eventlog.Open(windowsServiceName)
 	if elogErr == nil {
@@ -310,81 +398,24 @@ func (s *service) Execute(args []string, changeRequests <-chan svc.ChangeRequest
 	// Signal the service is starting
 	changes <- svc.Status{State: svc.StartPending}
 
-	// Load the configuration
-	config, err := anchor.LoadConfig()
-	if err != nil {
-		if elog != nil {
-			_ = elog.Error(3001, "failed to load configuration: "+err.Error())
-		}
-		return
-	}
-
-	// Initialize the anchor plugin
-	subprocess, err := anchor.NewAnchor()
-	if err != nil {
-		// Fallback path for Windows services: start the already-extracted temp binary
-		// without inheriting stdout/stderr handles from the service process.
-		pluginPath := filepath.Join(os.TempDir(), "anchor.exe")
-		cmd := exec.Command(pluginPath)
-		if startErr := cmd.Start(); startErr != nil {
-			if elog != nil {
-				_ = elog.Error(3002, "failed to initialize anchor plugin: "+err.Error()+"; inline start failed: "+startErr.Error())
-			}
-			return
-		}
-		if cmd.Process == nil {
-			if elog != nil {
-				_ = elog.Error(3002, "failed to initialize anchor plugin: inline process was nil")
-			}
-			return
-		}
-		subprocess = cmd
+	// Run the anchor in the background until the service is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- s.serviceImpl.Serve(ctx, func() { close(ready) })
+	}()
+
+	// Wait for the anchor to start
+	select {
+	case <-ready:
+	case err := <-done:
 		if elog != nil {
-			_ = elog.Warning(2002, "anchor initialized via inline subprocess fallback")
+			_ = elog.Error(3001, "failed to start anchor: "+errorString(err))
 		}
-	}
-	defer subprocess.Process.Kill()
-
-	// Wait for the subprocess to start
-	time.Sleep(1 * time.Second)
-
-	// Create a gRPC client connection
-	anchor, err := anchor.NewAnchorClient()
-	if err != nil {
-		if elog != nil {
-			_ = elog.Error(3003, "failed to create anchor gRPC client: "+err.Error())
-		}
-		return
-	}
-
-	var tracerConfig *pb.TracerConfig
-	if config.Tracer != nil {
-		tracerConfig = &pb.TracerConfig{
-			Enabled:  config.Tracer.Enabled,
-			Endpoint: config.Tracer.Endpoint,
-			UseTls:   config.Tracer.UseTLS,
-			Insecure: config.Tracer.Insecure,
-			Ca:       config.Tracer.CAFile,
-			Cert:     config.Tracer.CertFile,
-			Key:      config.Tracer.KeyFile,
-		}
-	}
-
-	// Start the anchor
-	_, err = anchor.StartAnchor(context.Background(), &pb.StartAnchorRequest{
-		GuardianUrl: config.Guardian,
-		AnchorToken: config.Token,
-		Ip:          config.IP,
-		Rift:        config.Rift,
-		Portal:      config.Portal,
-		Conduit:     config.Conduit,
-		Tracer:      tracerConfig,
-	})
-	if err != nil {
-		if elog != nil {
-			_ = elog.Error(3004, "failed to start anchor: "+err.Error())
-		}
-		return
+		changes <- svc.Status{State: svc.Stopped}
+		return false, 1
 	}
 
 	// Set the status to running
@@ -393,25 +424,73 @@ func (s *service) Execute(args []string, changeRequests <-chan svc.ChangeRequest
 		_ = elog.Info(1001, "service running")
 	}
 
-	// Monitor for service control requests and anchor context
-	for changeRequest := range changeRequests {
-		switch changeRequest.Cmd {
-		case svc.Interrogate:
-			changes <- changeRequest.CurrentStatus
-		case svc.Stop, svc.Shutdown:
-			// changes <- svc.Status{State: svc.StopPending}
-			// anchor.StopAnchor(context.Background(), &emptypb.Empty{})
+	// Monitor for service control requests and the anchor
+	for {
+		select {
+		case err := <-done:
 			if elog != nil {
-				_ = elog.Info(1002, "service stopping")
+				_ = elog.Error(3005, "anchor stopped: "+errorString(err))
 			}
 			changes <- svc.Status{State: svc.Stopped}
-			return false, 0
-		default:
-			if elog != nil {
-				_ = elog.Warning(2001, "unexpected service control request")
+			return false, 1
+		case changeRequest, ok := <-changeRequests:
+			if !ok {
+				cancel()
+				<-done
+				return false, 0
+			}
+			switch changeRequest.Cmd {
+			case svc.Interrogate:
+				changes <- changeRequest.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				if elog != nil {
+					_ = elog.Info(1002, "service stopping")
+				}
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-done
+				changes <- svc.Status{State: svc.Stopped}
+				return false, 0
+			default:
+				if elog != nil {
+					_ = elog.Warning(2001, "unexpected service control request")
+				}
+				changes <- changeRequest.CurrentStatus
 			}
-			changes <- changeRequest.CurrentStatus
 		}
 	}
-	return false, 0
+}
+
+// errorString returns err as a string, or "unknown error" when nil.
+func errorString(err error) string {
+	if err == nil {
+		return "unknown error"
+	}
+	return err.Error()
+}
+
+// newAnchorWithFallback starts the anchor subprocess; if extraction or start fails it starts the
+// already-extracted temp binary without redirecting its output (fallback path for Windows services).
+//
+// Inputs: none.
+//
+// Outputs:
+//   - *exec.Cmd. The started anchor subprocess.
+//   - err: error. Non-nil if both the regular and the fallback start fail.
+func newAnchorWithFallback() (*exec.Cmd, error) {
+	subprocess, err := anchor.NewAnchor()
+	if err == nil {
+		return subprocess, nil
+	}
+
+	pluginPath := filepath.Join(os.TempDir(), "anchor.exe")
+	cmd := exec.Command(pluginPath)
+	if startErr := cmd.Start(); startErr != nil {
+		return nil, fmt.Errorf("failed to initialize anchor plugin: %v; inline start failed: %w", err, startErr)
+	}
+	if cmd.Process == nil {
+		return nil, fmt.Errorf("failed to initialize anchor plugin: inline process was nil")
+	}
+	Logger.Sugar().Warnf("anchor initialized via inline subprocess fallback: %v", err)
+	return cmd, nil
 }
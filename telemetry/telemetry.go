@@ -0,0 +1,207 @@
+// Package telemetry exports the wrapper's own OpenTelemetry traces and metrics over OTLP.
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/veil-net/conflux/logger"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/stats"
+)
+
+// Logger re-exports the global logger for the telemetry package.
+var Logger = logger.Logger
+
+// instrumentationName is the name of the wrapper's tracer and meter.
+const instrumentationName = "github.com/veil-net/conflux"
+
+// ServiceName is the OpenTelemetry service name of the wrapper.
+const ServiceName = "veilnet-conflux"
+
+// Config holds the OTLP exporter settings; it mirrors anchor.TracerConfig so both share one configuration.
+type Config struct {
+	Enabled  bool
+	Endpoint string
+	UseTLS   bool
+	Insecure bool
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// phaseDuration records the duration of each startup phase (register, spawn, ready, start, taints).
+var phaseDuration metric.Float64Histogram
+
+// init creates the phase metric from the global meter provider, which forwards to the provider set by Setup.
+func init() {
+	var err error
+	phaseDuration, err = otel.Meter(instrumentationName).Float64Histogram(
+		"conflux.phase.duration",
+		metric.WithDescription("Duration of conflux startup phases."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TLSConfig builds the client TLS config from the CA, certificate and key files.
+//
+// Inputs:
+//   - config: *Config. The exporter settings; Insecure skips server certificate verification.
+//
+// Outputs:
+//   - *tls.Config. The TLS config.
+//   - err: error. Non-nil if a file cannot be read or parsed.
+func (config *Config) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.Insecure,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" || config.KeyFile != "" {
+		if config.CertFile == "" || config.KeyFile == "" {
+			return nil, errors.New("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Setup installs the global tracer and meter providers exporting to the OTLP endpoint.
+//
+// Inputs:
+//   - ctx: context.Context. Used to create the exporters.
+//   - config: *Config. The exporter settings; nil or disabled leaves telemetry as a no-op.
+//
+// Outputs:
+//   - func(context.Context) error. Flushes and shuts the providers down; always non-nil.
+//   - err: error. Non-nil if the TLS files are invalid or an exporter cannot be created.
+func Setup(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if config == nil || !config.Enabled {
+		return noop, nil
+	}
+	if config.Endpoint == "" {
+		return noop, errors.New("tracer is enabled but no OTLP endpoint is set")
+	}
+
+	// Build the transport options
+	traceOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	metricOptions := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(config.Endpoint)}
+	if config.UseTLS {
+		tlsConfig, err := config.TLSConfig()
+		if err != nil {
+			return noop, err
+		}
+		creds := credentials.NewTLS(tlsConfig)
+		traceOptions = append(traceOptions, otlptracegrpc.WithTLSCredentials(creds))
+		metricOptions = append(metricOptions, otlpmetricgrpc.WithTLSCredentials(creds))
+	} else {
+		traceOptions = append(traceOptions, otlptracegrpc.WithInsecure())
+		metricOptions = append(metricOptions, otlpmetricgrpc.WithInsecure())
+	}
+
+	// Create the exporters
+	traceExporter, err := otlptracegrpc.New(ctx, traceOptions...)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	metricExporter, err := otlpmetricgrpc.New(ctx, metricOptions...)
+	if err != nil {
+		traceExporter.Shutdown(ctx)
+		return noop, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
+	}
+
+	// Install the providers
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName))
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExporter),
+		sdktrace.WithResource(res),
+	)
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		Logger.Sugar().Debugf("telemetry export failed: %v", err)
+	}))
+	Logger.Sugar().Infof("exporting wrapper telemetry to %s", config.Endpoint)
+
+	return func(ctx context.Context) error {
+		return errors.Join(tracerProvider.Shutdown(ctx), meterProvider.Shutdown(ctx))
+	}, nil
+}
+
+// StartPhase starts a span for a startup phase; the returned function ends it and records its duration.
+//
+// Inputs:
+//   - ctx: context.Context. The parent context.
+//   - phase: string. The phase name, e.g. "register" or "anchor.spawn".
+//   - attrs: ...attribute.KeyValue. Extra span attributes.
+//
+// Outputs:
+//   - context.Context. The context carrying the span.
+//   - func(error). Call with the phase result to end the span.
+func StartPhase(ctx context.Context, phase string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, phase, trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		phaseDuration.Record(context.Background(), time.Since(start).Seconds(), metric.WithAttributes(
+			attribute.String("phase", phase),
+			attribute.String("outcome", outcome),
+		))
+	}
+}
+
+// GRPCClientHandler returns the gRPC stats handler tracing and measuring anchor calls.
+//
+// Inputs: none.
+//
+// Outputs:
+//   - stats.Handler. The handler to install on the anchor client.
+func GRPCClientHandler() stats.Handler {
+	return otelgrpc.NewClientHandler()
+}